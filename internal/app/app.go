@@ -1,19 +1,28 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
+	"cfiler/internal/archive"
 	"cfiler/internal/bookmark"
 	"cfiler/internal/dialog"
 	"cfiler/internal/fileops"
+	"cfiler/internal/job"
 	"cfiler/internal/pane"
+	"cfiler/internal/perms"
 	"cfiler/internal/preview"
+	"cfiler/internal/rename"
 	"cfiler/internal/session"
 	"cfiler/internal/statusbar"
+	"cfiler/internal/trash"
+	"cfiler/internal/vfs"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -29,6 +38,10 @@ const (
 	modeSearch
 	modeBookmark
 	modeHelp
+	modeJobs
+	modeTrash
+	modeRename
+	modePerms
 )
 
 type clipAction int
@@ -48,11 +61,21 @@ type App struct {
 	dialog     dialog.Dialog
 	bookmarks  bookmark.Model
 	searchInput textinput.Model
+	jobs       *job.Manager
+	jobView    job.Model
+	trashView  trash.Model
+	renameView rename.Model
+	permsView  perms.Model
 
 	mode               mode
 	clipboard          []string
 	clipAction         clipAction
 	pendingDeletePaths []string
+	pendingPaths       []string          // entries the open dialog acts on
+	pendingEdit        rename.EditedMsg  // names edited in $EDITOR awaiting a choice
+	conflicts          []job.ConflictMsg // conflicts waiting for the dialog
+	failures           []job.Status      // failure reports waiting for the dialog
+	tempOffered        map[string]bool   // dirs already offered stray temp cleanup
 	width              int
 	height             int
 	ready              bool
@@ -82,6 +105,8 @@ func New() App {
 		preview:     preview.New(),
 		statusBar:   statusbar.New(),
 		searchInput: si,
+		jobs:        job.NewManager(),
+		tempOffered: make(map[string]bool),
 		initCursor:  initCursor,
 	}
 }
@@ -99,7 +124,10 @@ func resolveStartDirs(state *session.State) (left, right string) {
 		if dir == "" {
 			return false
 		}
-		info, err := os.Stat(dir)
+		if vfs.Scheme(dir) != "" {
+			return false // remote panes are not reconnected at startup
+		}
+		info, err := vfs.Stat(dir)
 		return err == nil && info.IsDir()
 	}
 
@@ -151,6 +179,7 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		a.saveSession()
+		a.offerTempCleanup(msg.Path, msg.Entries)
 		cmds = append(cmds, a.loadPreviewCmd())
 		return a, tea.Batch(cmds...)
 
@@ -175,7 +204,10 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if cmd != nil {
 				cmds = append(cmds, cmd)
 			}
+		} else {
+			a.handleDialogCancel(msg)
 		}
+		a.showQueued()
 		return a, tea.Batch(cmds...)
 
 	case FileOpResultMsg:
@@ -191,8 +223,85 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		)
 		return a, tea.Batch(cmds...)
 
+	case job.ProgressMsg:
+		return a, a.jobs.Wait(msg.ID)
+
+	case job.DoneMsg:
+		if msg.Err != nil {
+			a.statusBar.SetMessage(fmt.Sprintf("%s failed: %v", msg.Kind, msg.Err), true)
+		} else if msg.Cancelled {
+			a.statusBar.SetMessage(fmt.Sprintf("%s cancelled: %d of %d items completed", msg.Kind, len(msg.Completed), len(msg.Items)), true)
+		} else if len(msg.Failures) > 0 {
+			a.statusBar.SetMessage(fmt.Sprintf("%s finished with %d errors", msg.Kind, len(msg.Failures)), true)
+			a.failures = append(a.failures, msg.Status)
+			a.showQueued()
+		} else {
+			a.statusBar.SetMessage(fmt.Sprintf("%s completed (%d files)", msg.Kind, msg.DoneFiles), false)
+		}
+		cmds = append(cmds,
+			pane.LoadDir(0, a.leftPane.Dir()),
+			pane.LoadDir(1, a.rightPane.Dir()),
+		)
+		return a, tea.Batch(cmds...)
+
+	case job.ConflictMsg:
+		a.conflicts = append(a.conflicts, msg)
+		a.showQueued()
+		return a, a.jobs.Wait(msg.JobID)
+
+	case job.CloseMsg:
+		a.mode = modeNormal
+		a.showQueued()
+		return a, nil
+
+	case trash.RestoreMsg:
+		a.mode = modeNormal
+		a.showQueued()
+		return a, a.jobs.Start(job.KindRestore, msg.Paths, "", fileops.Options{})
+
+	case trash.RemovedMsg:
+		var cmd tea.Cmd
+		a.trashView, cmd = a.trashView.Update(msg)
+		return a, cmd
+
+	case trash.CloseMsg:
+		a.mode = modeNormal
+		a.showQueued()
+		return a, nil
+
+	case rename.ApplyMsg:
+		a.mode = modeNormal
+		a.getActivePane().ClearMarks()
+		a.showQueued()
+		steps := msg.Steps
+		return a, func() tea.Msg {
+			err := fileops.RenameAll(steps)
+			return FileOpResultMsg{Err: err, Op: fmt.Sprintf("Rename of %d items", len(steps))}
+		}
+
+	case rename.CloseMsg:
+		a.mode = modeNormal
+		a.showQueued()
+		return a, nil
+
+	case rename.EditedMsg:
+		return a, a.handleEdited(msg)
+
+	case perms.ApplyMsg:
+		a.mode = modeNormal
+		a.getActivePane().ClearMarks()
+		a.showQueued()
+		attrs := msg.Attrs
+		return a, a.jobs.Start(job.KindAttrs, msg.Paths, "", fileops.Options{Attrs: &attrs})
+
+	case perms.CloseMsg:
+		a.mode = modeNormal
+		a.showQueued()
+		return a, nil
+
 	case bookmark.SelectMsg:
 		a.mode = modeNormal
+		a.showQueued()
 		active := a.getActivePane()
 		active.SetDir(msg.Path)
 		cmds = append(cmds, pane.LoadDir(active.ID(), msg.Path))
@@ -200,6 +309,7 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case bookmark.CloseMsg:
 		a.mode = modeNormal
+		a.showQueued()
 		return a, nil
 
 	case tea.KeyMsg:
@@ -217,9 +327,26 @@ func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return a.handleSearchKey(msg)
 	case modeBookmark:
 		return a.handleBookmarkKey(msg)
+	case modeJobs:
+		var cmd tea.Cmd
+		a.jobView, cmd = a.jobView.Update(msg)
+		return a, cmd
+	case modeTrash:
+		var cmd tea.Cmd
+		a.trashView, cmd = a.trashView.Update(msg)
+		return a, cmd
+	case modeRename:
+		var cmd tea.Cmd
+		a.renameView, cmd = a.renameView.Update(msg)
+		return a, cmd
+	case modePerms:
+		var cmd tea.Cmd
+		a.permsView, cmd = a.permsView.Update(msg)
+		return a, cmd
 	case modeHelp:
 		if msg.String() == "esc" || msg.String() == "?" || msg.String() == "q" {
 			a.mode = modeNormal
+			a.showQueued()
 		}
 		return a, nil
 	default:
@@ -236,6 +363,21 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		a.saveSession()
 		return a, tea.Quit
 
+	case key.Matches(msg, keys.Move, keys.Delete, keys.DeleteForever, keys.Rename,
+		keys.BatchRename, keys.EditRename, keys.Attrs, keys.Mkdir, keys.NewFile,
+		keys.Touch, keys.Duplicate, keys.Symlink, keys.Hardlink, keys.Compress) && inArchive(active):
+		a.statusBar.SetMessage("Archives are read-only", true)
+
+	case key.Matches(msg, keys.Paste, keys.PasteWith, keys.Symlink, keys.Hardlink, keys.Compress, keys.Extract) && inArchive(a.getOtherPane()):
+		a.statusBar.SetMessage("Cannot write into an archive", true)
+
+	case key.Matches(msg, keys.EditRename, keys.Attrs, keys.Touch, keys.Symlink, keys.Hardlink,
+		keys.Compress, keys.Extract, keys.Explorer) && isRemote(active):
+		a.statusBar.SetMessage("Not available on a remote pane", true)
+
+	case key.Matches(msg, keys.Symlink, keys.Hardlink, keys.Compress, keys.Extract) && isRemote(a.getOtherPane()):
+		a.statusBar.SetMessage("Cannot create this on a remote pane", true)
+
 	case key.Matches(msg, keys.Up):
 		active.MoveUp()
 		cmds = append(cmds, a.loadPreviewCmd())
@@ -268,14 +410,20 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					// Drive list: entry.Name is "C:\" etc.
 					newDir = entry.Name
 				} else if entry.Name == ".." {
-					newDir = filepath.Dir(active.Dir())
-					if newDir == active.Dir() && runtime.GOOS == "windows" {
+					newDir = vfs.Dir(active.Dir())
+					if newDir == active.Dir() && runtime.GOOS == "windows" && vfs.Scheme(newDir) == "" {
 						newDir = "" // go to drive list
 					}
 				} else {
-					newDir = filepath.Join(active.Dir(), entry.Name)
+					newDir = vfs.Join(active.Dir(), entry.Name)
 				}
 				cmds = append(cmds, pane.LoadDir(active.ID(), newDir))
+			} else if inArchive(active) {
+				a.statusBar.SetMessage("Copy the file out of the archive to open it", false)
+			} else if isRemote(active) {
+				a.statusBar.SetMessage("Copy the file to a local pane to open it", false)
+			} else if archive.Detect(entry.Name) != archive.FormatNone && vfs.IsLocal(active.Dir()) {
+				cmds = append(cmds, pane.LoadDir(active.ID(), active.SelectedPath()))
 			} else {
 				path := active.SelectedPath()
 				if err := fileops.OpenFile(path); err != nil {
@@ -288,10 +436,10 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if active.Dir() == "" {
 			// Already at drive list, do nothing
 		} else {
-			newDir := filepath.Dir(active.Dir())
+			newDir := vfs.Dir(active.Dir())
 			if newDir != active.Dir() {
 				cmds = append(cmds, pane.LoadDir(active.ID(), newDir))
-			} else if runtime.GOOS == "windows" {
+			} else if runtime.GOOS == "windows" && vfs.Scheme(newDir) == "" {
 				cmds = append(cmds, pane.LoadDir(active.ID(), ""))
 			}
 		}
@@ -320,7 +468,7 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if entry.Name != ".." {
 				a.clipboard = []string{path}
 				a.clipAction = clipCopy
-				a.statusBar.SetMessage(fmt.Sprintf("Copied to clipboard: %s", filepath.Base(path)), false)
+				a.statusBar.SetMessage(fmt.Sprintf("Copied to clipboard: %s", vfs.Base(path)), false)
 			}
 		}
 
@@ -334,62 +482,54 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if entry.Name != ".." {
 				a.clipboard = []string{path}
 				a.clipAction = clipMove
-				a.statusBar.SetMessage(fmt.Sprintf("Cut to clipboard: %s", filepath.Base(path)), false)
+				a.statusBar.SetMessage(fmt.Sprintf("Cut to clipboard: %s", vfs.Base(path)), false)
 			}
 		}
 
 	case key.Matches(msg, keys.Paste):
-		if len(a.clipboard) > 0 && a.clipAction != clipNone {
-			other := a.getOtherPane()
-			dst := other.Dir()
-			srcs := a.clipboard
-			action := a.clipAction
-			a.clipboard = nil
-			a.clipAction = clipNone
+		cmds = append(cmds, a.paste(fileops.Options{}))
+
+	case key.Matches(msg, keys.Duplicate):
+		if paths := selection(active); len(paths) > 0 {
 			active.ClearMarks()
+			cmds = append(cmds, a.jobs.Start(job.KindDuplicate, paths, "", fileops.Options{}))
+		}
 
-			if action == clipCopy {
-				cmds = append(cmds, func() tea.Msg {
-					for _, src := range srcs {
-						if err := fileops.Copy(src, dst); err != nil {
-							return FileOpResultMsg{Err: err, Op: "Copy"}
-						}
-					}
-					return FileOpResultMsg{Op: "Copy"}
-				})
-			} else {
-				cmds = append(cmds, func() tea.Msg {
-					for _, src := range srcs {
-						if err := fileops.Move(src, dst); err != nil {
-							return FileOpResultMsg{Err: err, Op: "Move"}
-						}
-					}
-					return FileOpResultMsg{Op: "Move"}
-				})
-			}
+	case key.Matches(msg, keys.PasteWith):
+		if len(a.clipboard) > 0 && a.clipAction != clipNone {
+			a.mode = modeDialog
+			a.dialog = dialog.NewSelect("Paste", "paste:", pasteChoices, a.width)
 		}
 
 	case key.Matches(msg, keys.Delete):
-		if active.MarkedCount() > 0 {
+		if !trash.Supported() || isRemote(active) {
+			a.confirmDelete(active)
+		} else if active.MarkedCount() > 0 {
 			a.pendingDeletePaths = active.MarkedPaths()
 			a.mode = modeDialog
 			a.dialog = dialog.NewConfirm(
-				"Delete",
-				fmt.Sprintf("Delete %d files?", len(a.pendingDeletePaths)),
-				"delete-multi:",
+				"Trash",
+				fmt.Sprintf("Move %d files to the trash?", len(a.pendingDeletePaths)),
+				"trash-multi:",
 				a.width,
 			)
 		} else if entry, ok := active.SelectedEntry(); ok && entry.Name != ".." {
 			path := active.SelectedPath()
 			a.mode = modeDialog
 			a.dialog = dialog.NewConfirm(
-				"Delete",
-				fmt.Sprintf("Delete %q?", entry.Name),
-				"delete:"+path,
+				"Trash",
+				fmt.Sprintf("Move %q to the trash?", entry.Name),
+				"trash:"+path,
 				a.width,
 			)
 		}
 
+	case key.Matches(msg, keys.DeleteForever):
+		a.confirmDelete(active)
+		if a.mode == modeDialog {
+			return a, textinput.Blink
+		}
+
 	case key.Matches(msg, keys.Rename):
 		if entry, ok := active.SelectedEntry(); ok && entry.Name != ".." {
 			path := active.SelectedPath()
@@ -403,6 +543,38 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			)
 		}
 
+	case key.Matches(msg, keys.BatchRename):
+		if paths := selection(active); len(paths) > 0 {
+			names := make([]string, len(paths))
+			for i, p := range paths {
+				names[i] = vfs.Base(p)
+			}
+			a.mode = modeRename
+			a.renameView = rename.NewModel(active.Dir(), names, a.width, a.height)
+			return a, textinput.Blink
+		}
+
+	case key.Matches(msg, keys.EditRename):
+		var names []string
+		if active.MarkedCount() > 0 {
+			names = active.MarkedNames()
+		} else {
+			for _, e := range active.Entries() {
+				if e.Name != ".." {
+					names = append(names, e.Name)
+				}
+			}
+		}
+		if len(names) > 0 && active.Dir() != "" {
+			cmds = append(cmds, rename.Edit(active.Dir(), names))
+		}
+
+	case key.Matches(msg, keys.Attrs):
+		if paths := selection(active); len(paths) > 0 {
+			a.mode = modePerms
+			a.permsView = perms.NewModel(paths, a.width, a.height)
+		}
+
 	case key.Matches(msg, keys.Mkdir):
 		a.mode = modeDialog
 		a.dialog = dialog.NewInput(
@@ -413,6 +585,74 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			a.width,
 		)
 
+	case key.Matches(msg, keys.NewFile):
+		a.mode = modeDialog
+		d := dialog.NewInput(
+			"New File",
+			"newfile:"+active.Dir(),
+			"file name",
+			"",
+			a.width,
+		)
+		if !isRemote(active) {
+			d.WithAlt("ctrl+e", "newfile-edit:"+active.Dir(), "Ctrl+E to create and edit")
+		}
+		a.dialog = d
+
+	case key.Matches(msg, keys.Touch):
+		if paths := selection(active); len(paths) > 0 {
+			a.pendingPaths = paths
+			a.mode = modeDialog
+			d := dialog.NewInput(
+				fmt.Sprintf("Set timestamps of %d entries", len(paths)),
+				"touch:",
+				"YYYY-MM-DD [HH:MM[:SS]], empty for now",
+				"",
+				a.width,
+			)
+			if hasDir(paths) {
+				d.WithAlt("ctrl+r", "touch-recursive:", "Ctrl+R to include contents")
+			}
+			a.dialog = d
+		}
+
+	case key.Matches(msg, keys.Compress):
+		if paths := selection(active); len(paths) > 0 {
+			name := vfs.Base(active.Dir())
+			if len(paths) == 1 {
+				name = vfs.Base(paths[0])
+			}
+			a.pendingPaths = paths
+			a.mode = modeDialog
+			a.dialog = dialog.NewInput(
+				fmt.Sprintf("Compress %d entries to the other pane", len(paths)),
+				"compress:",
+				".zip, .tar.gz, .tar.xz or .tar.zst",
+				name+".zip",
+				a.width,
+			)
+		}
+
+	case key.Matches(msg, keys.Extract):
+		paths := selection(active)
+		for _, p := range paths {
+			if _, _, ok := archive.Split(p); !ok {
+				a.statusBar.SetMessage(fmt.Sprintf("Not an archive: %s", vfs.Base(p)), true)
+				paths = nil
+				break
+			}
+		}
+		if len(paths) > 0 {
+			a.pendingPaths = paths
+			a.mode = modeDialog
+			a.dialog = dialog.NewSelect(
+				fmt.Sprintf("Extract %d items into %s", len(paths), a.getOtherPane().Dir()),
+				"extract:",
+				extractChoices,
+				a.width,
+			)
+		}
+
 	case key.Matches(msg, keys.Search):
 		a.mode = modeSearch
 		a.updateLayout()
@@ -427,7 +667,7 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case key.Matches(msg, keys.BookAdd):
 		dir := active.Dir()
-		name := filepath.Base(dir)
+		name := vfs.Base(dir)
 		if err := bookmark.Add(name, dir); err != nil {
 			a.statusBar.SetMessage(fmt.Sprintf("Bookmark error: %v", err), true)
 		} else {
@@ -466,6 +706,48 @@ func (a App) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			a.statusBar.SetMessage(fmt.Sprintf("Open failed: %v", err), true)
 		}
 
+	case key.Matches(msg, keys.Undo):
+		cmds = append(cmds, func() tea.Msg {
+			op, err := fileops.Undo(context.Background())
+			return FileOpResultMsg{Err: err, Op: describeOp("Undo", op)}
+		})
+
+	case key.Matches(msg, keys.Redo):
+		cmds = append(cmds, func() tea.Msg {
+			op, err := fileops.Redo(context.Background())
+			return FileOpResultMsg{Err: err, Op: describeOp("Redo", op)}
+		})
+
+	case key.Matches(msg, keys.Symlink):
+		if paths := selection(active); len(paths) > 0 {
+			a.pendingPaths = paths
+			a.mode = modeDialog
+			a.dialog = dialog.NewSelect(
+				fmt.Sprintf("Symlink %d items into %s", len(paths), a.getOtherPane().Dir()),
+				"symlink:",
+				symlinkChoices,
+				a.width,
+			)
+		}
+
+	case key.Matches(msg, keys.Hardlink):
+		if paths := selection(active); len(paths) > 0 {
+			active.ClearMarks()
+			cmds = append(cmds, a.jobs.Start(job.KindLink, paths, a.getOtherPane().Dir(), fileops.Options{}))
+		}
+
+	case key.Matches(msg, keys.Trash):
+		if !trash.Supported() {
+			a.statusBar.SetMessage("Trash is not supported on this platform", true)
+		} else {
+			a.mode = modeTrash
+			a.trashView = trash.NewModel(a.width, a.height)
+		}
+
+	case key.Matches(msg, keys.Jobs):
+		a.mode = modeJobs
+		a.jobView = job.NewModel(a.jobs, a.width, a.height)
+
 	case key.Matches(msg, keys.Help):
 		a.mode = modeHelp
 	}
@@ -491,11 +773,13 @@ func (a App) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		active.EndSearch(true)
 		a.mode = modeNormal
 		a.updateLayout()
+		a.showQueued()
 		return a, a.loadPreviewCmd()
 	case "esc":
 		active.EndSearch(false)
 		a.mode = modeNormal
 		a.updateLayout()
+		a.showQueued()
 		return a, nil
 	default:
 		var cmd tea.Cmd
@@ -511,6 +795,196 @@ func (a App) handleBookmarkKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return a, cmd
 }
 
+var pasteChoices = []dialog.Choice{
+	{Value: "normal", Label: "Paste (copy symlinks as links)"},
+	{Value: "deref", Label: "Paste, following symlinks"},
+	{Value: "verify", Label: "Paste and verify checksums"},
+	{Value: "clone", Label: "Paste as copy-on-write clones"},
+}
+
+var extractChoices = []dialog.Choice{
+	{Value: "folder", Label: "Into a folder named after the archive"},
+	{Value: "here", Label: "Directly into the directory"},
+}
+
+var editChoices = []dialog.Choice{
+	{Value: "trash", Label: "Rename and move removed entries to the trash"},
+	{Value: "keep", Label: "Rename only, keep removed entries"},
+}
+
+// handleEdited applies the result of editing names in $EDITOR, asking
+// first what to do with entries whose lines were removed.
+func (a *App) handleEdited(msg rename.EditedMsg) tea.Cmd {
+	if msg.Err != nil {
+		a.statusBar.SetMessage(fmt.Sprintf("Rename failed: %v", msg.Err), true)
+		return nil
+	}
+	if len(msg.Deleted) > 0 && !trash.Supported() {
+		// Removing lines is not taken as a permanent delete.
+		msg.Deleted = nil
+	}
+	if len(msg.Deleted) > 0 {
+		a.pendingEdit = msg
+		a.mode = modeDialog
+		a.dialog = dialog.NewSelect(
+			fmt.Sprintf("%d entries were removed from the list", len(msg.Deleted)),
+			"edit:",
+			editChoices,
+			a.width,
+		)
+		return nil
+	}
+	return a.applyEdit(msg)
+}
+
+// applyEdit trashes the deleted entries, which may free names the renames
+// need, and then performs the renames.
+func (a *App) applyEdit(edit rename.EditedMsg) tea.Cmd {
+	rename.Check(edit.Existing, edit.Results, edit.Deleted)
+	changed := len(edit.Deleted)
+	for _, r := range edit.Results {
+		if r.Problem != "" {
+			a.statusBar.SetMessage(fmt.Sprintf("Rename failed: %s: %s", r.New, r.Problem), true)
+			return nil
+		}
+		if r.Changed() {
+			changed++
+		}
+	}
+	if changed == 0 {
+		a.statusBar.SetMessage("Nothing to rename", false)
+		return nil
+	}
+	a.getActivePane().ClearMarks()
+	return func() tea.Msg {
+		if len(edit.Deleted) > 0 {
+			b := fileops.NewBatch(fileops.OpTrash)
+			for _, name := range edit.Deleted {
+				if err := fileops.Trash(context.Background(), filepath.Join(edit.Dir, name), fileops.Options{Batch: b}); err != nil {
+					_ = b.Commit()
+					return FileOpResultMsg{Err: err, Op: "Trash"}
+				}
+			}
+			_ = b.Commit()
+		}
+		err := fileops.RenameAll(rename.Steps(edit.Dir, edit.Results))
+		return FileOpResultMsg{Err: err, Op: "Rename"}
+	}
+}
+
+var symlinkChoices = []dialog.Choice{
+	{Value: "absolute", Label: "Absolute links"},
+	{Value: "relative", Label: "Relative links"},
+}
+
+// selection returns the marked entries of p, or the entry under the
+// cursor when nothing is marked.
+func selection(p *pane.Model) []string {
+	if p.MarkedCount() > 0 {
+		return p.MarkedPaths()
+	}
+	if entry, ok := p.SelectedEntry(); ok && entry.Name != ".." {
+		return []string{p.SelectedPath()}
+	}
+	return nil
+}
+
+// inArchive reports whether p is showing the inside of an archive.
+func inArchive(p *pane.Model) bool {
+	_, _, ok := archive.Split(p.Dir())
+	return ok
+}
+
+// isRemote reports whether p is showing a remote location.
+func isRemote(p *pane.Model) bool {
+	return vfs.Scheme(p.Dir()) != ""
+}
+
+// hasDir reports whether any of paths is a directory.
+func hasDir(paths []string) bool {
+	for _, p := range paths {
+		if info, err := vfs.Lstat(p); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+}
+
+// parseTime reads a date in local time or RFC 3339 form. An empty string
+// means now.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "now" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD HH:MM:SS", s)
+}
+
+// paste starts a copy or move job for the clipboard into the other pane.
+func (a *App) paste(opts fileops.Options) tea.Cmd {
+	if len(a.clipboard) == 0 || a.clipAction == clipNone {
+		return nil
+	}
+	dst := a.getOtherPane().Dir()
+	srcs := a.clipboard
+	action := a.clipAction
+	a.clipboard = nil
+	a.clipAction = clipNone
+	a.getActivePane().ClearMarks()
+
+	if action == clipCopy {
+		return a.jobs.Start(job.KindCopy, srcs, dst, opts)
+	}
+	return a.jobs.Start(job.KindMove, srcs, dst, opts)
+}
+
+// confirmDelete asks for permanent deletion of the marked entries or the
+// entry under the cursor. Where a trash is available this is the
+// destructive path, so the user has to type "yes" instead of pressing y.
+func (a *App) confirmDelete(active *pane.Model) {
+	var title, message, action string
+	if active.MarkedCount() > 0 {
+		a.pendingDeletePaths = active.MarkedPaths()
+		title = "Delete"
+		message = fmt.Sprintf("Delete %d files?", len(a.pendingDeletePaths))
+		action = "delete-multi:"
+	} else if entry, ok := active.SelectedEntry(); ok && entry.Name != ".." {
+		title = "Delete"
+		message = fmt.Sprintf("Delete %q?", entry.Name)
+		action = "delete:" + active.SelectedPath()
+	} else {
+		return
+	}
+
+	a.mode = modeDialog
+	if !trash.Supported() {
+		a.dialog = dialog.NewConfirm(title, message, action, a.width)
+		return
+	}
+	a.dialog = dialog.NewInput(
+		title+" Permanently: "+message,
+		action,
+		`type "yes" to delete permanently`,
+		"",
+		a.width,
+	)
+}
+
 func (a *App) handleDialogResult(msg dialog.ResultMsg) tea.Cmd {
 	parts := strings.SplitN(msg.Action, ":", 2)
 	if len(parts) != 2 {
@@ -520,24 +994,29 @@ func (a *App) handleDialogResult(msg dialog.ResultMsg) tea.Cmd {
 	target := parts[1]
 
 	switch action {
-	case "delete":
-		return func() tea.Msg {
-			err := fileops.Delete(target)
-			return FileOpResultMsg{Err: err, Op: "Delete"}
+	case "delete", "delete-multi":
+		paths := []string{target}
+		if action == "delete-multi" {
+			paths = a.pendingDeletePaths
+		}
+		a.pendingDeletePaths = nil
+		if trash.Supported() && strings.TrimSpace(msg.Text) != "yes" {
+			a.statusBar.SetMessage("Delete cancelled", false)
+			return nil
 		}
-	case "delete-multi":
+		if action == "delete-multi" {
+			active := a.getActivePane()
+			active.ClearMarks()
+		}
+		return a.jobs.Start(job.KindDelete, paths, "", fileops.Options{})
+	case "trash":
+		return a.jobs.Start(job.KindTrash, []string{target}, "", fileops.Options{})
+	case "trash-multi":
 		paths := a.pendingDeletePaths
 		a.pendingDeletePaths = nil
 		active := a.getActivePane()
 		active.ClearMarks()
-		return func() tea.Msg {
-			for _, p := range paths {
-				if err := fileops.Delete(p); err != nil {
-					return FileOpResultMsg{Err: err, Op: "Delete"}
-				}
-			}
-			return FileOpResultMsg{Op: "Delete"}
-		}
+		return a.jobs.Start(job.KindTrash, paths, "", fileops.Options{})
 	case "rename":
 		return func() tea.Msg {
 			err := fileops.Rename(target, msg.Text)
@@ -548,21 +1027,232 @@ func (a *App) handleDialogResult(msg dialog.ResultMsg) tea.Cmd {
 			err := fileops.Mkdir(target, msg.Text)
 			return FileOpResultMsg{Err: err, Op: "Mkdir"}
 		}
+	case "newfile", "newfile-edit":
+		name := msg.Text
+		edit := action == "newfile-edit"
+		return func() tea.Msg {
+			if err := fileops.CreateFile(target, name); err != nil {
+				return FileOpResultMsg{Err: err, Op: "Create"}
+			}
+			if !edit {
+				return FileOpResultMsg{Op: "Create"}
+			}
+			cmd := fileops.EditCommand(filepath.Join(target, name))
+			return tea.ExecProcess(cmd, func(err error) tea.Msg {
+				return FileOpResultMsg{Err: err, Op: "Edit"}
+			})()
+		}
+	case "touch", "touch-recursive":
+		paths := a.pendingPaths
+		a.pendingPaths = nil
+		t, err := parseTime(msg.Text)
+		if err != nil {
+			a.statusBar.SetMessage(fmt.Sprintf("Touch failed: %v", err), true)
+			return nil
+		}
+		a.getActivePane().ClearMarks()
+		attrs := fileops.Attrs{UID: -1, GID: -1, Time: t, Recursive: action == "touch-recursive"}
+		return a.jobs.Start(job.KindTouch, paths, "", fileops.Options{Attrs: &attrs})
+	case "compress":
+		paths := a.pendingPaths
+		a.pendingPaths = nil
+		name := strings.TrimSpace(msg.Text)
+		if !rename.ValidName(name) {
+			// The archive goes into the other pane's directory itself.
+			a.statusBar.SetMessage(fmt.Sprintf("Invalid archive name: %s", name), true)
+			return nil
+		}
+		if archive.Detect(name) == archive.FormatNone {
+			a.statusBar.SetMessage("Unknown archive type: use .zip, .tar.gz, .tar.xz or .tar.zst", true)
+			return nil
+		}
+		dst := filepath.Join(a.getOtherPane().Dir(), name)
+		if _, err := os.Lstat(dst); err == nil {
+			a.statusBar.SetMessage(fmt.Sprintf("%s already exists", name), true)
+			return nil
+		}
+		a.getActivePane().ClearMarks()
+		return a.jobs.Start(job.KindCompress, paths, dst, fileops.Options{})
+	case "paste":
+		var opts fileops.Options
+		switch msg.Text {
+		case "deref":
+			opts.Dereference = true
+		case "verify":
+			opts.Verify = true
+		case "clone":
+			opts.Clone = true
+		}
+		return a.paste(opts)
+	case "clean-temp":
+		return func() tea.Msg {
+			_, err := fileops.CleanTemp(target)
+			return FileOpResultMsg{Err: err, Op: "Cleanup"}
+		}
+	case "extract":
+		paths := a.pendingPaths
+		a.pendingPaths = nil
+		a.getActivePane().ClearMarks()
+		opts := fileops.Options{Folder: msg.Text == "folder"}
+		return a.jobs.Start(job.KindExtract, paths, a.getOtherPane().Dir(), opts)
+	case "symlink":
+		paths := a.pendingPaths
+		a.pendingPaths = nil
+		a.getActivePane().ClearMarks()
+		opts := fileops.Options{Relative: msg.Text == "relative"}
+		return a.jobs.Start(job.KindSymlink, paths, a.getOtherPane().Dir(), opts)
+	case "edit":
+		edit := a.pendingEdit
+		a.pendingEdit = rename.EditedMsg{}
+		if msg.Text == "keep" {
+			edit.Deleted = nil
+		}
+		return a.applyEdit(edit)
+	case "retry":
+		if id, err := strconv.Atoi(target); err == nil {
+			return a.jobs.Retry(id)
+		}
+	case "conflict":
+		id, err := strconv.Atoi(target)
+		if err != nil {
+			return nil
+		}
+		choice, all := strings.CutSuffix(msg.Text, ":all")
+		if action, ok := conflictActions[choice]; ok {
+			a.jobs.Resolve(id, action, all)
+		}
 	case "goto":
 		paneID := 0
 		if target == "1" {
 			paneID = 1
 		}
-		dir := msg.Text
-		if !filepath.IsAbs(dir) {
+		dir := vfs.Clean(msg.Text)
+		if !vfs.IsAbs(dir) {
 			p := a.getActivePane()
-			dir = filepath.Join(p.Dir(), dir)
+			dir = vfs.Join(p.Dir(), dir)
 		}
 		return pane.LoadDir(paneID, dir)
 	}
 	return nil
 }
 
+var conflictActions = map[string]fileops.ConflictAction{
+	"overwrite": fileops.ConflictOverwrite,
+	"skip":      fileops.ConflictSkip,
+	"rename":    fileops.ConflictRename,
+	"newer":     fileops.ConflictOverwriteNewer,
+}
+
+func (a *App) handleDialogCancel(msg dialog.ResultMsg) {
+	action, target, _ := strings.Cut(msg.Action, ":")
+	switch action {
+	case "conflict":
+		// Dismissing a conflict aborts the job that raised it.
+		if id, err := strconv.Atoi(target); err == nil {
+			a.jobs.Cancel(id)
+		}
+	}
+}
+
+// busy reports whether the user is in a dialog, the search bar or an
+// overlay, which a conflict or failure dialog must not replace.
+func (a *App) busy() bool {
+	return a.mode != modeNormal
+}
+
+// showQueued opens the dialog for the oldest queued conflict whose job is
+// still running, or else the oldest failure report, unless the user is
+// busy with something else.
+func (a *App) showQueued() {
+	if a.busy() {
+		return
+	}
+	for len(a.conflicts) > 0 {
+		msg := a.conflicts[0]
+		a.conflicts = a.conflicts[1:]
+		if !a.jobs.IsRunning(msg.JobID) {
+			continue
+		}
+		a.mode = modeDialog
+		a.dialog = dialog.NewConflict(
+			describeConflict(msg.Conflict),
+			fmt.Sprintf("conflict:%d", msg.JobID),
+			a.width,
+		)
+		return
+	}
+	if len(a.failures) > 0 {
+		s := a.failures[0]
+		a.failures = a.failures[1:]
+		a.showFailures(s)
+	}
+}
+
+// showFailures opens the failure report of a finished job.
+func (a *App) showFailures(s job.Status) {
+	lines := make([]string, len(s.Failures))
+	for i, f := range s.Failures {
+		lines[i] = fmt.Sprintf("%s: %v", f.Src, f.Err)
+	}
+	a.mode = modeDialog
+	a.dialog = dialog.NewReport(
+		fmt.Sprintf("%s: %d failed", s.Kind, len(s.Failures)),
+		lines,
+		fmt.Sprintf("retry:%d", s.ID),
+		a.width,
+		a.height,
+	)
+}
+
+// offerTempCleanup asks once per directory whether to remove unfinished
+// copies left by an interrupted run. Only files that have not been written
+// to for a while are counted, as jobs here or in another cfiler may still
+// be writing the others, and nothing is offered while jobs are running.
+func (a *App) offerTempCleanup(dir string, entries []pane.FileEntry) {
+	if a.mode != modeNormal || a.tempOffered[dir] || len(a.jobs.Running()) > 0 {
+		return
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir && fileops.IsStaleTemp(e.Name, e.ModTime) {
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	a.tempOffered[dir] = true
+	a.mode = modeDialog
+	a.dialog = dialog.NewConfirm(
+		"Cleanup",
+		fmt.Sprintf("%d unfinished copies found in %s. Remove them?", n, dir),
+		"clean-temp:"+dir,
+		a.width,
+	)
+}
+
+func describeOp(verb string, op fileops.Op) string {
+	if op.Kind == "" {
+		return verb
+	}
+	if len(op.Steps) == 1 {
+		return fmt.Sprintf("%s %s of %s", verb, op.Kind, vfs.Base(op.Steps[0].To))
+	}
+	return fmt.Sprintf("%s %s of %d items", verb, op.Kind, len(op.Steps))
+}
+
+func describeConflict(c fileops.Conflict) string {
+	const layout = "2006-01-02 15:04"
+	describe := func(info os.FileInfo) string {
+		if info.IsDir() {
+			return fmt.Sprintf("directory, %s", info.ModTime().Format(layout))
+		}
+		return fmt.Sprintf("%d bytes, %s", info.Size(), info.ModTime().Format(layout))
+	}
+	return fmt.Sprintf("%q already exists.\n\nSource:      %s\nDestination: %s",
+		c.Dst, describe(c.SrcInfo), describe(c.DstInfo))
+}
+
 func (a *App) saveSession() {
 	_ = session.Save(session.State{
 		LeftDir:     a.leftPane.Dir(),
@@ -661,7 +1351,7 @@ func (a App) View() string {
 
 	// Status bar
 	active := a.getActivePane()
-	statusView := a.statusBar.View(active, a.mode == modeSearch, a.searchInput.Value())
+	statusView := a.statusBar.View(active, a.mode == modeSearch, a.searchInput.Value(), a.jobs.Running())
 
 	var mainView string
 	if a.mode == modeSearch {
@@ -678,6 +1368,14 @@ func (a App) View() string {
 		}
 	case modeBookmark:
 		return a.overlayCenter(mainView, a.bookmarks.View())
+	case modeJobs:
+		return a.overlayCenter(mainView, a.jobView.View())
+	case modeTrash:
+		return a.overlayCenter(mainView, a.trashView.View())
+	case modeRename:
+		return a.overlayCenter(mainView, a.renameView.View())
+	case modePerms:
+		return a.overlayCenter(mainView, a.permsView.View())
 	case modeHelp:
 		return a.overlayCenter(mainView, a.helpView())
 	}
@@ -717,7 +1415,7 @@ func (a App) helpView() string {
 
 	helpItems := []struct{ key, desc string }{
 		{"↑/↓", "Move cursor"},
-		{"Enter", "Open dir/file"},
+		{"Enter", "Open dir/archive/file"},
 		{"Backspace", "Parent directory"},
 		{"Tab", "Switch pane"},
 		{"PgUp/PgDn", "Page scroll"},
@@ -728,13 +1426,29 @@ func (a App) helpView() string {
 		{"F5/c", "Copy to clipboard"},
 		{"F6/m", "Move to clipboard"},
 		{"p", "Paste to other pane"},
+		{"P", "Paste with options"},
+		{"Shift+F5/y", "Duplicate in place"},
+		{"z", "Compress to other pane"},
+		{"x", "Extract to other pane"},
 		{"F7/n", "New directory"},
-		{"F8/d", "Delete"},
+		{"F8/d", "Move to trash"},
+		{"Shift+F8/D", "Delete permanently"},
 		{"r", "Rename"},
+		{"R", "Batch rename (pattern/regex)"},
+		{"E", "Rename in $EDITOR"},
+		{"A", "Permissions / owner"},
+		{"N", "New file"},
+		{"Ctrl+T", "Set timestamps (touch)"},
+		{"l", "Symlink to other pane"},
+		{"L", "Hard link to other pane"},
+		{"u", "Undo"},
+		{"Ctrl+R", "Redo"},
 		{"/", "Search"},
 		{"t", "Toggle preview"},
-		{"g", "Go to directory"},
+		{"g", "Go to directory or sftp://user@host/path"},
 		{"e", "Open in explorer"},
+		{"T", "Trash (restore/empty)"},
+		{"J", "Jobs / progress"},
 		{"b", "Bookmarks"},
 		{"B", "Add bookmark"},
 		{"?", "This help"},
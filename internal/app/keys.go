@@ -3,34 +3,50 @@ package app
 import "github.com/charmbracelet/bubbles/key"
 
 type keyMap struct {
-	Up       key.Binding
-	Down     key.Binding
-	Enter    key.Binding
-	Back     key.Binding
-	Tab      key.Binding
-	PageUp   key.Binding
-	PageDown key.Binding
-	Home     key.Binding
-	End      key.Binding
-	Copy     key.Binding
-	Move     key.Binding
-	Paste    key.Binding
-	Mkdir    key.Binding
-	Delete   key.Binding
-	Rename   key.Binding
-	Search   key.Binding
-	Escape   key.Binding
-	Bookmark key.Binding
-	BookAdd  key.Binding
-	Toggle    key.Binding
-	Help      key.Binding
-	Quit      key.Binding
-	MarkToggle key.Binding
-	SelectAll  key.Binding
-	ShiftUp    key.Binding
-	ShiftDown  key.Binding
-	GotoDir    key.Binding
-	Explorer   key.Binding
+	Up            key.Binding
+	Down          key.Binding
+	Enter         key.Binding
+	Back          key.Binding
+	Tab           key.Binding
+	PageUp        key.Binding
+	PageDown      key.Binding
+	Home          key.Binding
+	End           key.Binding
+	Copy          key.Binding
+	Move          key.Binding
+	Paste         key.Binding
+	PasteWith     key.Binding
+	Mkdir         key.Binding
+	Delete        key.Binding
+	DeleteForever key.Binding
+	Rename        key.Binding
+	Search        key.Binding
+	Escape        key.Binding
+	Bookmark      key.Binding
+	BookAdd       key.Binding
+	Toggle        key.Binding
+	Help          key.Binding
+	Quit          key.Binding
+	MarkToggle    key.Binding
+	SelectAll     key.Binding
+	ShiftUp       key.Binding
+	ShiftDown     key.Binding
+	GotoDir       key.Binding
+	Explorer      key.Binding
+	Jobs          key.Binding
+	Trash         key.Binding
+	Undo          key.Binding
+	Redo          key.Binding
+	Symlink       key.Binding
+	Hardlink      key.Binding
+	BatchRename   key.Binding
+	EditRename    key.Binding
+	Attrs         key.Binding
+	NewFile       key.Binding
+	Touch         key.Binding
+	Duplicate     key.Binding
+	Compress      key.Binding
+	Extract       key.Binding
 }
 
 var keys = keyMap{
@@ -82,18 +98,38 @@ var keys = keyMap{
 		key.WithKeys("p"),
 		key.WithHelp("p", "paste"),
 	),
+	PasteWith: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "paste with options"),
+	),
 	Mkdir: key.NewBinding(
 		key.WithKeys("f7", "n"),
 		key.WithHelp("F7/n", "mkdir"),
 	),
 	Delete: key.NewBinding(
 		key.WithKeys("f8", "d"),
-		key.WithHelp("F8/d", "delete"),
+		key.WithHelp("F8/d", "trash"),
+	),
+	DeleteForever: key.NewBinding(
+		key.WithKeys("shift+f8", "D"),
+		key.WithHelp("Shift+F8/D", "delete permanently"),
 	),
 	Rename: key.NewBinding(
 		key.WithKeys("r"),
 		key.WithHelp("r", "rename"),
 	),
+	BatchRename: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "batch rename"),
+	),
+	EditRename: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "rename in $EDITOR"),
+	),
+	Attrs: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "permissions/owner"),
+	),
 	Search: key.NewBinding(
 		key.WithKeys("/"),
 		key.WithHelp("/", "search"),
@@ -146,4 +182,48 @@ var keys = keyMap{
 		key.WithKeys("e"),
 		key.WithHelp("e", "explorer"),
 	),
+	Jobs: key.NewBinding(
+		key.WithKeys("J"),
+		key.WithHelp("J", "jobs"),
+	),
+	Trash: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "trash"),
+	),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "undo"),
+	),
+	Redo: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("Ctrl+R", "redo"),
+	),
+	Symlink: key.NewBinding(
+		key.WithKeys("l"),
+		key.WithHelp("l", "symlink to other pane"),
+	),
+	Hardlink: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "hard link to other pane"),
+	),
+	NewFile: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("N", "new file"),
+	),
+	Touch: key.NewBinding(
+		key.WithKeys("ctrl+t"),
+		key.WithHelp("Ctrl+T", "set timestamps"),
+	),
+	Duplicate: key.NewBinding(
+		key.WithKeys("shift+f5", "y"),
+		key.WithHelp("Shift+F5/y", "duplicate"),
+	),
+	Compress: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("z", "compress to other pane"),
+	),
+	Extract: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "extract to other pane"),
+	),
 }
@@ -0,0 +1,133 @@
+// Package archive reads zip and tar archives so they can be browsed like
+// read-only directories, and writes new ones. A location inside an archive is written as a
+// path through the archive file, such as /home/me/logs.tar.gz/2024/app.log.
+package archive
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	FormatNone Format = iota
+	FormatZip
+	FormatTar
+	FormatTarGz
+	FormatTarZst
+	FormatTarXz
+)
+
+var suffixes = []struct {
+	suffix string
+	format Format
+}{
+	{".zip", FormatZip},
+	{".tar", FormatTar},
+	{".tar.gz", FormatTarGz},
+	{".tgz", FormatTarGz},
+	{".tar.zst", FormatTarZst},
+	{".tzst", FormatTarZst},
+	{".tar.xz", FormatTarXz},
+	{".txz", FormatTarXz},
+}
+
+// Detect returns the archive format of a file name, judged by its
+// extension.
+func Detect(name string) Format {
+	lower := strings.ToLower(name)
+	for _, s := range suffixes {
+		if strings.HasSuffix(lower, s.suffix) && len(lower) > len(s.suffix) {
+			return s.format
+		}
+	}
+	return FormatNone
+}
+
+// Stem returns name without its archive extension, such as "logs" for
+// "logs.tar.gz".
+func Stem(name string) string {
+	lower := strings.ToLower(name)
+	for _, s := range suffixes {
+		if strings.HasSuffix(lower, s.suffix) && len(lower) > len(s.suffix) {
+			return name[:len(name)-len(s.suffix)]
+		}
+	}
+	return name
+}
+
+// Split finds the archive file that p leads through. inner is the
+// slash-separated location inside it, empty for the archive's root. ok is
+// false for ordinary paths.
+func Split(p string) (archivePath, inner string, ok bool) {
+	for dir := p; ; {
+		if Detect(dir) != FormatNone {
+			if info, err := os.Stat(dir); err == nil && info.Mode().IsRegular() {
+				rel, err := filepath.Rel(dir, p)
+				if err != nil {
+					return "", "", false
+				}
+				if rel == "." {
+					rel = ""
+				}
+				return dir, filepath.ToSlash(rel), true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// Entry is a file, directory or link stored in an archive.
+type Entry struct {
+	Name    string // slash-separated path inside the archive
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+
+	// Link is the target of a symlink, or for a tar hard link the name of
+	// the entry it shares its contents with.
+	Link     string
+	Hardlink bool
+}
+
+func (e Entry) IsDir() bool { return e.Mode.IsDir() }
+
+// Info returns e as an fs.FileInfo.
+func (e Entry) Info() fs.FileInfo { return entryInfo{e} }
+
+type entryInfo struct{ e Entry }
+
+func (i entryInfo) Name() string       { return path.Base(i.e.Name) }
+func (i entryInfo) Size() int64        { return i.e.Size }
+func (i entryInfo) Mode() fs.FileMode  { return i.e.Mode }
+func (i entryInfo) ModTime() time.Time { return i.e.ModTime }
+func (i entryInfo) IsDir() bool        { return i.e.IsDir() }
+func (i entryInfo) Sys() any           { return nil }
+
+// cleanName normalizes a stored name to a relative slash-separated path.
+// Names that climb out with ".." keep their leading ".." elements; they
+// are left out of listings and refused on extraction.
+func cleanName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimLeft(name, "/")
+	name = path.Clean(name)
+	if name == "." {
+		return ""
+	}
+	return name
+}
+
+// Unsafe reports whether name would leave the directory it is extracted
+// into.
+func Unsafe(name string) bool {
+	return name == ".." || strings.HasPrefix(name, "../")
+}
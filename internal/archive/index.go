@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// index is the table of contents of an archive. Directories that are only
+// implied by the names of their contents are filled in.
+type index struct {
+	entries  map[string]Entry
+	children map[string][]string
+}
+
+func buildIndex(archivePath string) (*index, error) {
+	idx := &index{
+		entries:  map[string]Entry{"": {Mode: fs.ModeDir | 0755}},
+		children: make(map[string][]string),
+	}
+	err := Walk(archivePath, "", func(e Entry, _ io.Reader) error {
+		if !Unsafe(e.Name) {
+			idx.add(e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, e := range idx.entries {
+		// Hard links are stored without data; show the size they share.
+		if e.Hardlink {
+			if target, ok := idx.entries[e.Link]; ok {
+				e.Size = target.Size
+				idx.entries[name] = e
+			}
+		}
+	}
+	return idx, nil
+}
+
+func (idx *index) add(e Entry) {
+	_, seen := idx.entries[e.Name]
+	idx.entries[e.Name] = e
+	if seen {
+		return
+	}
+	parent := path.Dir(e.Name)
+	if parent == "." {
+		parent = ""
+	}
+	idx.children[parent] = append(idx.children[parent], e.Name)
+	if _, ok := idx.entries[parent]; !ok {
+		idx.add(Entry{Name: parent, Mode: fs.ModeDir | 0755, ModTime: e.ModTime})
+	}
+}
+
+type cached struct {
+	size    int64
+	modTime time.Time
+	idx     *index
+}
+
+// maxCached bounds how many archive indexes are kept between listings.
+const maxCached = 8
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]cached)
+)
+
+// load returns the index of an archive, reading it again only when the
+// file has changed since the last time.
+func load(archivePath string) (*index, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	c, ok := cache[archivePath]
+	cacheMu.Unlock()
+	if ok && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return c.idx, nil
+	}
+
+	idx, err := buildIndex(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	if len(cache) >= maxCached {
+		clear(cache)
+	}
+	cache[archivePath] = cached{size: info.Size(), modTime: info.ModTime(), idx: idx}
+	cacheMu.Unlock()
+	return idx, nil
+}
+
+// List returns the entries directly inside dir, sorted by name.
+func List(archivePath, dir string) ([]Entry, error) {
+	idx, err := load(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := idx.entries[dir]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: dir, Err: fs.ErrNotExist}
+	}
+	if !e.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", dir)
+	}
+	names := idx.children[dir]
+	entries := make([]Entry, len(names))
+	for i, name := range names {
+		entries[i] = idx.entries[name]
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// Stat returns the entry called name; "" is the archive's root.
+func Stat(archivePath, name string) (Entry, error) {
+	idx, err := load(archivePath)
+	if err != nil {
+		return Entry{}, err
+	}
+	e, ok := idx.entries[name]
+	if !ok {
+		return Entry{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Measure counts the files and bytes at or below name.
+func Measure(archivePath, name string) (files int, bytes int64) {
+	idx, err := load(archivePath)
+	if err != nil {
+		return 0, 0
+	}
+	for n, e := range idx.entries {
+		if n == "" || !under(n, name) || e.IsDir() {
+			continue
+		}
+		files++
+		if e.Mode.IsRegular() && !e.Hardlink {
+			bytes += e.Size
+		}
+	}
+	return files, bytes
+}
@@ -0,0 +1,204 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ErrStop can be returned by a WalkFunc to end the walk early without an
+// error.
+var ErrStop = errors.New("stop walking")
+
+// WalkFunc receives each entry in the order it is stored. r holds the
+// contents of regular files and is nil for everything else; it is only
+// valid until the function returns.
+type WalkFunc func(e Entry, r io.Reader) error
+
+// Walk reads the archive once from start to end, calling fn for the
+// entries at or below prefix ("" for all of them).
+func Walk(archivePath, prefix string, fn WalkFunc) error {
+	format := Detect(archivePath)
+	if format == FormatZip {
+		return walkZip(archivePath, prefix, fn)
+	}
+	if format == FormatNone {
+		return fmt.Errorf("not an archive: %s", archivePath)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f, format)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return walkTar(r, prefix, fn)
+}
+
+// Open returns the contents of the regular file name inside the archive.
+func Open(archivePath, name string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	found := make(chan error, 1)
+	go func() {
+		err := Walk(archivePath, name, func(e Entry, r io.Reader) error {
+			if e.Name != name {
+				return nil
+			}
+			if r == nil {
+				return fmt.Errorf("not a regular file: %s", name)
+			}
+			found <- nil
+			if _, err := io.Copy(pw, r); err != nil {
+				return err
+			}
+			return ErrStop
+		})
+		if errors.Is(err, ErrStop) {
+			err = nil
+		} else if err == nil {
+			err = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		found <- err
+		pw.CloseWithError(err)
+	}()
+	if err := <-found; err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func under(name, prefix string) bool {
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error { return rc.close() }
+
+func decompress(r io.Reader, format Format) (io.ReadCloser, error) {
+	switch format {
+	case FormatTarGz:
+		return gzip.NewReader(r)
+	case FormatTarZst:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{Reader: d, close: func() error { d.Close(); return nil }}, nil
+	case FormatTarXz:
+		x, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(x), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+func walkTar(r io.Reader, prefix string, fn WalkFunc) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		// Unsafe names are reported to fn like any other.
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			return err
+		}
+		e := Entry{
+			Name:    cleanName(hdr.Name),
+			Size:    hdr.Size,
+			Mode:    hdr.FileInfo().Mode(),
+			ModTime: hdr.ModTime,
+		}
+		var contents io.Reader
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeGNUSparse:
+			contents = tr
+		case tar.TypeDir:
+		case tar.TypeSymlink:
+			e.Link = hdr.Linkname
+		case tar.TypeLink:
+			e.Link = cleanName(hdr.Linkname)
+			e.Hardlink = true
+			e.Mode = e.Mode.Perm()
+		default:
+			// Devices, fifos and the like are not extracted.
+			continue
+		}
+		if e.Name == "" || !under(e.Name, prefix) {
+			continue
+		}
+		if err := fn(e, contents); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archivePath, prefix string, fn WalkFunc) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		e := Entry{
+			Name:    cleanName(f.Name),
+			Size:    int64(f.UncompressedSize64),
+			Mode:    f.Mode(),
+			ModTime: f.Modified,
+		}
+		if e.Name == "" || !under(e.Name, prefix) {
+			continue
+		}
+		if e.IsDir() {
+			e.Size = 0
+			if err := fn(e, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := walkZipFile(f, e, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkZipFile(f *zip.File, e Entry, fn WalkFunc) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// Symlinks are stored with their target as the contents.
+	if e.Mode&fs.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		e.Link = string(target)
+		return fn(e, nil)
+	}
+	if !e.Mode.IsRegular() {
+		return nil
+	}
+	return fn(e, rc)
+}
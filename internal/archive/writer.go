@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Writer creates an archive in one of the supported formats.
+type Writer struct {
+	zw   *zip.Writer
+	tw   *tar.Writer
+	comp io.WriteCloser // compressor under tw, if any
+}
+
+// NewWriter starts an archive of the given format on w. Close must be
+// called to complete it; it does not close w.
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	if format == FormatZip {
+		return &Writer{zw: zip.NewWriter(w)}, nil
+	}
+
+	aw := &Writer{}
+	var err error
+	switch format {
+	case FormatTar:
+	case FormatTarGz:
+		aw.comp = gzip.NewWriter(w)
+	case FormatTarZst:
+		aw.comp, err = zstd.NewWriter(w)
+	case FormatTarXz:
+		aw.comp, err = xz.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("cannot create this kind of archive")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if aw.comp != nil {
+		w = aw.comp
+	}
+	aw.tw = tar.NewWriter(w)
+	return aw, nil
+}
+
+// Add starts the entry name, a slash-separated path, described by info.
+// link is the target of a symlink. For regular files the returned writer
+// takes the contents, which must be exactly info.Size() bytes; for other
+// entries it is nil.
+func (w *Writer) Add(name string, info fs.FileInfo, link string) (io.Writer, error) {
+	name = strings.TrimSuffix(name, "/")
+	if info.IsDir() {
+		name += "/"
+	}
+	if w.zw != nil {
+		return w.addZip(name, info, link)
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	// PAX keeps sub-second times and long names.
+	hdr.Format = tar.FormatPAX
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	return w.tw, nil
+}
+
+func (w *Writer) addZip(name string, info fs.FileInfo, link string) (io.Writer, error) {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	if info.Mode().IsRegular() {
+		hdr.Method = zip.Deflate
+	}
+	fw, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		// Symlinks are stored with their target as the contents.
+		_, err = io.WriteString(fw, link)
+		return nil, err
+	case info.Mode().IsRegular():
+		return fw, nil
+	}
+	return nil, nil
+}
+
+// Close writes the end of the archive and flushes the compressor.
+func (w *Writer) Close() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.comp != nil {
+		return w.comp.Close()
+	}
+	return nil
+}
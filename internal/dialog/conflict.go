@@ -0,0 +1,100 @@
+package dialog
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ConflictDialog asks what to do about a destination that already exists.
+// The chosen answer is returned in ResultMsg.Text as "overwrite", "skip",
+// "rename" or "newer", followed by ":all" when "apply to all" is on.
+type ConflictDialog struct {
+	message  string
+	action   string
+	applyAll bool
+	width    int
+}
+
+func NewConflict(message, action string, width int) *ConflictDialog {
+	return &ConflictDialog{
+		message: message,
+		action:  action,
+		width:   width,
+	}
+}
+
+func (d *ConflictDialog) Update(msg tea.Msg) (Dialog, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		var choice string
+		switch msg.String() {
+		case "o", "O":
+			choice = "overwrite"
+		case "s", "S":
+			choice = "skip"
+		case "r", "R":
+			choice = "rename"
+		case "n", "N":
+			choice = "newer"
+		case "a", "A":
+			d.applyAll = !d.applyAll
+			return d, nil
+		case "esc":
+			return d, func() tea.Msg {
+				return ResultMsg{Confirmed: false, Action: d.action}
+			}
+		}
+		if choice != "" {
+			if d.applyAll {
+				choice += ":all"
+			}
+			return d, func() tea.Msg {
+				return ResultMsg{Confirmed: true, Text: choice, Action: d.action}
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *ConflictDialog) View() string {
+	dialogW := d.width / 2
+	if dialogW < 50 {
+		dialogW = 50
+	}
+	if dialogW > d.width-4 {
+		dialogW = d.width - 4
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+
+	msgStyle := lipgloss.NewStyle().
+		Width(dialogW - 4)
+
+	promptStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+
+	check := "[ ]"
+	if d.applyAll {
+		check = "[x]"
+	}
+
+	content := fmt.Sprintf("%s\n\n%s\n\n%s\n%s\n%s",
+		titleStyle.Render("File Exists"),
+		msgStyle.Render(d.message),
+		promptStyle.Render("[O]verwrite  [S]kip  [R]ename  Overwrite if [N]ewer"),
+		promptStyle.Render(check+" [A]pply to all"),
+		promptStyle.Render("Esc to cancel the job"),
+	)
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(content)
+}
@@ -13,6 +13,11 @@ type InputDialog struct {
 	action    string
 	textInput textinput.Model
 	width     int
+
+	// An optional second way to confirm, such as "create and edit".
+	altKey    string
+	altAction string
+	altHelp   string
 }
 
 func NewInput(title, action, placeholder, initial string, width int) *InputDialog {
@@ -31,10 +36,30 @@ func NewInput(title, action, placeholder, initial string, width int) *InputDialo
 	}
 }
 
+// WithAlt makes key confirm the input with altAction instead of the
+// dialog's action. help describes the key in the prompt line.
+func (d *InputDialog) WithAlt(key, altAction, help string) *InputDialog {
+	d.altKey = key
+	d.altAction = altAction
+	d.altHelp = help
+	return d
+}
+
 func (d *InputDialog) Update(msg tea.Msg) (Dialog, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
+		case d.altKey:
+			if d.altKey == "" {
+				break
+			}
+			return d, func() tea.Msg {
+				return ResultMsg{
+					Confirmed: true,
+					Text:      d.textInput.Value(),
+					Action:    d.altAction,
+				}
+			}
 		case "enter":
 			return d, func() tea.Msg {
 				return ResultMsg{
@@ -71,10 +96,14 @@ func (d *InputDialog) View() string {
 	promptStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#565f89"))
 
+	prompt := "Enter to confirm / Esc to cancel"
+	if d.altKey != "" {
+		prompt = "Enter to confirm / " + d.altHelp + " / Esc to cancel"
+	}
 	content := fmt.Sprintf("%s\n\n%s\n\n%s",
 		titleStyle.Render(d.title),
 		d.textInput.View(),
-		promptStyle.Render("Enter to confirm / Esc to cancel"),
+		promptStyle.Render(prompt),
 	)
 
 	boxStyle := lipgloss.NewStyle().
@@ -0,0 +1,125 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ReportDialog shows a scrollable list of lines, such as the failures of a
+// batch operation. Pressing r confirms the action (e.g. a retry); any
+// closing key cancels it.
+type ReportDialog struct {
+	title  string
+	lines  []string
+	action string
+	offset int
+	width  int
+	height int
+}
+
+func NewReport(title string, lines []string, action string, width, height int) *ReportDialog {
+	return &ReportDialog{
+		title:  title,
+		lines:  lines,
+		action: action,
+		width:  width,
+		height: height,
+	}
+}
+
+func (d *ReportDialog) Update(msg tea.Msg) (Dialog, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			d.scroll(-1)
+		case "down", "j":
+			d.scroll(1)
+		case "pgup":
+			d.scroll(-d.visibleLines())
+		case "pgdown":
+			d.scroll(d.visibleLines())
+		case "r", "R":
+			return d, func() tea.Msg {
+				return ResultMsg{Confirmed: true, Action: d.action}
+			}
+		case "esc", "enter", "q":
+			return d, func() tea.Msg {
+				return ResultMsg{Confirmed: false, Action: d.action}
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *ReportDialog) scroll(n int) {
+	d.offset += n
+	if max := len(d.lines) - d.visibleLines(); d.offset > max {
+		d.offset = max
+	}
+	if d.offset < 0 {
+		d.offset = 0
+	}
+}
+
+func (d *ReportDialog) visibleLines() int {
+	// border, padding, title, blank lines and footer
+	h := d.height - 12
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+func (d *ReportDialog) View() string {
+	dialogW := d.width * 2 / 3
+	if dialogW < 50 {
+		dialogW = 50
+	}
+	if dialogW > d.width-4 {
+		dialogW = d.width - 4
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+
+	lineStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#db4b4b")).
+		Width(dialogW - 6).
+		MaxHeight(1)
+
+	promptStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+
+	end := d.offset + d.visibleLines()
+	if end > len(d.lines) {
+		end = len(d.lines)
+	}
+	var lines []string
+	for _, line := range d.lines[d.offset:end] {
+		lines = append(lines, lineStyle.Render(line))
+	}
+
+	position := ""
+	if len(d.lines) > d.visibleLines() {
+		position = fmt.Sprintf(" (%d-%d of %d)", d.offset+1, end, len(d.lines))
+	}
+
+	content := fmt.Sprintf("%s\n\n%s\n\n%s",
+		titleStyle.Render(d.title+position),
+		strings.Join(lines, "\n"),
+		promptStyle.Render("↑/↓: scroll  r: retry failed  Esc: close"),
+	)
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(content)
+}
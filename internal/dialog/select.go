@@ -0,0 +1,105 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Choice is one entry of a SelectDialog. Value is returned in
+// ResultMsg.Text when the entry is picked.
+type Choice struct {
+	Value string
+	Label string
+}
+
+type SelectDialog struct {
+	title   string
+	action  string
+	choices []Choice
+	cursor  int
+	width   int
+}
+
+func NewSelect(title, action string, choices []Choice, width int) *SelectDialog {
+	return &SelectDialog{
+		title:   title,
+		action:  action,
+		choices: choices,
+		width:   width,
+	}
+}
+
+func (d *SelectDialog) Update(msg tea.Msg) (Dialog, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if d.cursor > 0 {
+				d.cursor--
+			}
+		case "down", "j":
+			if d.cursor < len(d.choices)-1 {
+				d.cursor++
+			}
+		case "enter":
+			if d.cursor < len(d.choices) {
+				value := d.choices[d.cursor].Value
+				return d, func() tea.Msg {
+					return ResultMsg{Confirmed: true, Text: value, Action: d.action}
+				}
+			}
+		case "esc":
+			return d, func() tea.Msg {
+				return ResultMsg{Confirmed: false, Action: d.action}
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *SelectDialog) View() string {
+	dialogW := d.width / 2
+	if dialogW < 40 {
+		dialogW = 40
+	}
+	if dialogW > d.width-4 {
+		dialogW = d.width - 4
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#7aa2f7")).
+		Bold(true)
+
+	promptStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+
+	var lines []string
+	for i, c := range d.choices {
+		if i == d.cursor {
+			lines = append(lines, selectedStyle.Render("▸ "+c.Label))
+		} else {
+			lines = append(lines, "  "+c.Label)
+		}
+	}
+
+	content := fmt.Sprintf("%s\n\n%s\n\n%s",
+		titleStyle.Render(d.title),
+		strings.Join(lines, "\n"),
+		promptStyle.Render("Enter to select / Esc to cancel"),
+	)
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(content)
+}
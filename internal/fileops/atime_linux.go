@@ -0,0 +1,14 @@
+package fileops
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+func atime(info fs.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package fileops
+
+import (
+	"io/fs"
+	"time"
+)
+
+// atime falls back to the modification time where the access time is not
+// read from the platform's stat structure.
+func atime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,158 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Attrs is a change of permissions, ownership and times made by SetAttrs.
+type Attrs struct {
+	// SetFileMode applies FileMode to files and SetDirMode DirMode to
+	// directories. Both hold the permission bits plus ModeSetuid,
+	// ModeSetgid and ModeSticky.
+	SetFileMode bool
+	SetDirMode  bool
+	FileMode    fs.FileMode
+	DirMode     fs.FileMode
+
+	// UID and GID are the new owner and group; -1 keeps the current one.
+	UID int
+	GID int
+
+	// Time, when not zero, becomes the access and modification time.
+	Time time.Time
+
+	// Recursive applies the change to everything below directories too.
+	Recursive bool
+}
+
+// SetAttrs applies opts.Attrs to path. Directories are changed after their
+// contents, so a mode that takes away access does not stop the walk.
+func SetAttrs(ctx context.Context, path string, opts Options) error {
+	if opts.Attrs == nil {
+		return errors.New("no attributes to set")
+	}
+	if err := localOnly("Changing attributes", path); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !opts.Attrs.Recursive {
+		if err := applyAttrs(path, info, opts.Attrs); err != nil {
+			return err
+		}
+		accountTree(path, opts.progress())
+		return nil
+	}
+	return setAttrsTree(ctx, path, info, opts)
+}
+
+func setAttrsTree(ctx context.Context, path string, info fs.FileInfo, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p := opts.progress()
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			child := filepath.Join(path, entry.Name())
+			childInfo, err := entry.Info()
+			if err == nil {
+				err = setAttrsTree(ctx, child, childInfo, opts)
+			}
+			if err != nil {
+				if err := opts.fail(child, "", err); err != nil {
+					return err
+				}
+			}
+		}
+		return applyAttrs(path, info, opts.Attrs)
+	}
+
+	p.FileStarted(path, 0)
+	if err := applyAttrs(path, info, opts.Attrs); err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() {
+		p.BytesDone(info.Size())
+	}
+	p.FileDone(path)
+	return nil
+}
+
+func applyAttrs(path string, info fs.FileInfo, a *Attrs) error {
+	if a.UID >= 0 || a.GID >= 0 {
+		if err := os.Lchown(path, a.UID, a.GID); err != nil {
+			return err
+		}
+	}
+	// Changing the owner clears setuid and setgid, so the mode goes on
+	// afterwards. Symlinks have no mode of their own.
+	mode, set := a.FileMode, a.SetFileMode
+	if info.IsDir() {
+		mode, set = a.DirMode, a.SetDirMode
+	}
+	if set && info.Mode()&fs.ModeSymlink == 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if !a.Time.IsZero() {
+		return lchtimes(path, a.Time, a.Time)
+	}
+	return nil
+}
+
+// LookupUser returns the uid for a user name or number.
+func LookupUser(name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return -1, fmt.Errorf("unknown user %q", name)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// LookupGroup returns the gid for a group name or number.
+func LookupGroup(name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return -1, fmt.Errorf("unknown group %q", name)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// OwnerNames returns the user and group owning info, as names where they
+// can be looked up and as numbers otherwise. Both are empty on platforms
+// without Unix ownership.
+func OwnerNames(info fs.FileInfo) (userName, groupName string) {
+	uid, gid, ok := owner(info)
+	if !ok {
+		return "", ""
+	}
+	userName, groupName = strconv.Itoa(uid), strconv.Itoa(gid)
+	if u, err := user.LookupId(userName); err == nil {
+		userName = u.Username
+	}
+	if g, err := user.LookupGroupId(groupName); err == nil {
+		groupName = g.Name
+	}
+	return userName, groupName
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package fileops
+
+import "os"
+
+// dropCache is a no-op where the page cache cannot be dropped per file;
+// verification then may read back from memory.
+func dropCache(f *os.File) {}
@@ -0,0 +1,32 @@
+package fileops
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkFS lists the filesystems where a clone is known to share extents
+// instead of copying data.
+var reflinkFS = map[int64]bool{
+	unix.BTRFS_SUPER_MAGIC:    true,
+	unix.XFS_SUPER_MAGIC:      true,
+	unix.BCACHEFS_SUPER_MAGIC: true,
+}
+
+// canReflink reports whether f lives on a filesystem that supports
+// copy-on-write clones.
+func canReflink(f *os.File) bool {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(f.Fd()), &st); err != nil {
+		return false
+	}
+	return reflinkFS[int64(st.Type)]
+}
+
+// cloneFile makes out share in's data with FICLONE. It fails without
+// touching out when the filesystem cannot clone or the files are on
+// different filesystems.
+func cloneFile(out, in *os.File) bool {
+	return unix.IoctlFileClone(int(out.Fd()), int(in.Fd())) == nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package fileops
+
+import "os"
+
+// Copy-on-write clones are only implemented on Linux.
+func canReflink(f *os.File) bool { return false }
+
+func cloneFile(out, in *os.File) bool { return false }
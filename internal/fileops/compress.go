@@ -0,0 +1,145 @@
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"cfiler/internal/archive"
+)
+
+// Compress packs srcs into a new archive at dst, in the format its
+// extension names. Each source is stored under its own name. The archive
+// is written under a temporary name and only appears at dst once it is
+// complete; an existing dst is never replaced.
+func Compress(ctx context.Context, srcs []string, dst string, opts Options) (err error) {
+	format := archive.Detect(dst)
+	if format == archive.FormatNone {
+		return fmt.Errorf("unknown archive type: %s", filepath.Base(dst))
+	}
+	if err := localOnly("Compressing", filepath.Dir(dst)); err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if err := localOnly("Compressing", src); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %s", dst)
+	}
+
+	out, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	tmp := out.Name()
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	w, err := archive.NewWriter(out, format)
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if err := addTree(ctx, w, src, tmp, opts); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return renameNoReplace(tmp, dst)
+}
+
+// addTree stores src and everything below it, named relative to the
+// directory holding src. skip is the archive being written, which may lie
+// inside the tree.
+func addTree(ctx context.Context, w *archive.Writer, src, skip string, opts Options) error {
+	p := opts.progress()
+	base := filepath.Dir(src)
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == skip {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		var link string
+		switch {
+		case info.IsDir():
+			_, err := w.Add(name, info, "")
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular():
+			// Sockets, devices and fifos are left out.
+			p.FileDone(path)
+			return nil
+		}
+
+		p.FileStarted(path, info.Size())
+		if err := addFile(ctx, w, name, path, info, link, p); err != nil {
+			return err
+		}
+		p.FileDone(path)
+		return nil
+	})
+}
+
+func addFile(ctx context.Context, w *archive.Writer, name, path string, info fs.FileInfo, link string, p Progress) error {
+	if !info.Mode().IsRegular() {
+		_, err := w.Add(name, info, link)
+		return err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// The header is written before the data, so the size has to be known
+	// from the open file rather than an earlier stat.
+	if info, err = in.Stat(); err != nil {
+		return err
+	}
+	fw, err := w.Add(name, info, "")
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(progressWriter{w: fw, p: p}, ctxReader{ctx: ctx, r: io.LimitReader(in, info.Size())})
+	if err != nil {
+		return err
+	}
+	if n != info.Size() {
+		return fmt.Errorf("file shrank while being archived: %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cfiler/internal/vfs"
+)
+
+// ConflictAction is the answer to a destination that already exists.
+type ConflictAction int
+
+const (
+	ConflictOverwrite ConflictAction = iota
+	ConflictSkip
+	ConflictRename
+	ConflictOverwriteNewer
+)
+
+// Conflict describes a copy or move whose destination already exists.
+type Conflict struct {
+	Src     string
+	Dst     string
+	SrcInfo fs.FileInfo
+	DstInfo fs.FileInfo
+}
+
+// Resolver decides how to handle a conflict. It may block, for example
+// while the user is asked, and should return ctx.Err() if ctx is cancelled.
+type Resolver func(ctx context.Context, c Conflict) (ConflictAction, error)
+
+var errSameFile = errors.New("source and destination are the same file")
+
+// resolveDst works out where src should be written given that dst may
+// already exist. It returns the path to write to, or skip=true when the
+// item should be left alone. merge=true means dst is an existing directory
+// that src's contents should be merged into.
+func resolveDst(ctx context.Context, src, dst string, srcInfo fs.FileInfo, opts Options) (path string, skip, merge bool, err error) {
+	dstInfo, err := vfs.Lstat(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dst, false, false, nil
+	}
+	if err != nil {
+		return "", false, false, err
+	}
+	if srcInfo.IsDir() && dstInfo.IsDir() && !os.SameFile(srcInfo, dstInfo) {
+		return dst, false, true, nil
+	}
+	path, skip, err = resolveExisting(ctx, src, dst, srcInfo, dstInfo, opts)
+	return path, skip, false, err
+}
+
+// resolveExisting asks opts.Resolve what to do about the existing dst.
+func resolveExisting(ctx context.Context, src, dst string, srcInfo, dstInfo fs.FileInfo, opts Options) (path string, skip bool, err error) {
+	if opts.Resolve == nil {
+		return "", false, fmt.Errorf("destination already exists: %s", dst)
+	}
+	action, err := opts.Resolve(ctx, Conflict{Src: src, Dst: dst, SrcInfo: srcInfo, DstInfo: dstInfo})
+	if err != nil {
+		return "", false, err
+	}
+
+	if action == ConflictOverwriteNewer {
+		if !srcInfo.ModTime().After(dstInfo.ModTime()) {
+			return "", true, nil
+		}
+		action = ConflictOverwrite
+	}
+
+	switch action {
+	case ConflictSkip:
+		return "", true, nil
+	case ConflictRename:
+		return UniqueName(dst), false, nil
+	case ConflictOverwrite:
+		if os.SameFile(srcInfo, dstInfo) {
+			return "", false, errSameFile
+		}
+		// A regular file is replaced by the final rename, so the old
+		// contents stay in place until the new ones are complete.
+		if srcInfo.Mode().IsRegular() && dstInfo.Mode().IsRegular() {
+			return dst, false, nil
+		}
+		if err := vfs.RemoveAll(dst); err != nil {
+			return "", false, err
+		}
+		return dst, false, nil
+	}
+	return "", false, fmt.Errorf("unknown conflict action %d", action)
+}
+
+// UniqueName returns path, or if that exists, the first free variant of
+// the form "name (N).ext".
+func UniqueName(path string) string {
+	// A name that cannot be looked up counts as free; writing to it
+	// reports the actual problem instead of searching forever.
+	if _, err := vfs.Lstat(path); err != nil {
+		return path
+	}
+	dir := vfs.Dir(path)
+	base := vfs.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; ; i++ {
+		candidate := vfs.Join(dir, fmt.Sprintf("%s (%d)%s", stem, i, ext))
+		if _, err := vfs.Lstat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
+
+// accountTree reports everything below path as done without copying it,
+// so progress totals still add up for skipped or renamed items.
+func accountTree(path string, p Progress) {
+	files, bytes := Measure([]string{path})
+	for i := 0; i < files; i++ {
+		p.FileDone(path)
+	}
+	p.BytesDone(bytes)
+}
@@ -0,0 +1,257 @@
+package fileops
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// progressWriter forwards the number of bytes written to a Progress.
+type progressWriter struct {
+	w io.Writer
+	p Progress
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.BytesDone(int64(n))
+	return n, err
+}
+
+// ctxReader fails reads once its context is done, interrupting io.Copy.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr ctxReader) Read(b []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(b)
+}
+
+// copyWorkers bounds how many files are copied at the same time.
+const copyWorkers = 8
+
+// copier copies a tree on the local disk. The calling goroutine walks it
+// once, creating directories and symlinks in order and resolving conflicts
+// one at a time, while a pool of workers copies the regular files.
+type copier struct {
+	tree
+	cancel context.CancelFunc
+	files  chan fileTask
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	err error // first worker error that aborts the copy
+}
+
+type fileTask struct {
+	src, dst string
+	info     fs.FileInfo
+	batch    *Batch // where to record the file once it is complete
+}
+
+func newCopier(ctx context.Context, opts Options) *copier {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &copier{
+		cancel: cancel,
+		files:  make(chan fileTask, copyWorkers),
+	}
+	c.tree = tree{ctx: ctx, opts: opts, w: c}
+	c.wg.Add(copyWorkers)
+	for i := 0; i < copyWorkers; i++ {
+		go c.worker()
+	}
+	return c
+}
+
+func (c *copier) worker() {
+	defer c.wg.Done()
+	for t := range c.files {
+		if c.ctx.Err() != nil {
+			continue
+		}
+		err := copyFile(c.ctx, t.src, t.dst, t.info, c.opts)
+		if err == nil {
+			t.batch.add(t.src, t.dst)
+			continue
+		}
+		if err := c.opts.fail(t.src, t.dst, err); err != nil {
+			c.abort(err)
+		}
+	}
+}
+
+// abort stops the copy after an error that OnError did not take.
+func (c *copier) abort(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// finish waits for the queued files and applies directory metadata. err is
+// the result of the walk; an error from a worker takes precedence over the
+// cancellation it caused.
+func (c *copier) finish(err error) error {
+	if err != nil {
+		c.cancel()
+	}
+	close(c.files)
+	c.wg.Wait()
+	defer c.cancel()
+
+	err = finishDirs(c.dirs, copyMetadata, c.opts, err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		return c.err
+	}
+	return err
+}
+
+// copyItem copies src to dst, consulting opts.Resolve if dst exists.
+func copyItem(ctx context.Context, src, dst string, info fs.FileInfo, opts Options) error {
+	c := newCopier(ctx, opts)
+	return c.finish(c.item(src, dst, info, opts))
+}
+
+// copyNode copies src to a dst that does not exist yet, preserving
+// metadata the way cp -a does.
+func copyNode(ctx context.Context, src, dst string, info fs.FileInfo, opts Options) error {
+	c := newCopier(ctx, opts)
+	return c.finish(c.node(src, dst, info, opts))
+}
+
+// mkdir makes the directory owner-only until the contents are in place,
+// so a read-only source directory does not stop us from filling the copy.
+func (c *copier) mkdir(dst string) error {
+	return os.Mkdir(dst, 0700)
+}
+
+func (c *copier) symlink(src, dst string, info fs.FileInfo, opts Options) error {
+	return copySymlink(src, dst, info, opts)
+}
+
+// file queues the file for the workers, which record it once complete.
+func (c *copier) file(src, dst string, info fs.FileInfo, opts Options) error {
+	select {
+	case c.files <- fileTask{src: src, dst: dst, info: info, batch: opts.Batch}:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+func (c *copier) setMetadata(dst string, info fs.FileInfo) error {
+	return copyMetadata(dst, info)
+}
+
+func copyFile(ctx context.Context, src, dst string, info fs.FileInfo, opts Options) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p := opts.progress()
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	p.FileStarted(src, info.Size())
+
+	out, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	tmp := out.Name()
+	defer func() {
+		if err != nil {
+			out.Close()
+			// Never leave a half-written file behind.
+			os.Remove(tmp)
+		}
+	}()
+
+	h := sha256.New()
+	if err := copyData(ctx, out, in, info.Size(), h, opts); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if opts.Verify {
+		if err := verifyFile(ctx, out, h.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := copyMetadata(tmp, info); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	p.FileDone(src)
+	return nil
+}
+
+// copyData copies the contents of in to out. Unless the data has to pass
+// through h for verification, the file is cloned on filesystems that
+// support it. Holes in sparse files are kept, and otherwise the kernel
+// copies the data where it can.
+func copyData(ctx context.Context, out, in *os.File, size int64, h hash.Hash, opts Options) error {
+	p := opts.progress()
+	var sum io.Writer
+	if opts.Verify {
+		sum = h
+	}
+	if !opts.Verify && (opts.Clone || canReflink(out)) && cloneFile(out, in) {
+		p.BytesDone(size)
+		return nil
+	}
+	if ok, err := copySparse(ctx, out, in, size, sum, p); ok || err != nil {
+		return err
+	}
+	if !opts.Verify {
+		if ok, err := copyRange(ctx, out, in, p); ok || err != nil {
+			return err
+		}
+	}
+	var r io.Reader = in
+	if opts.Verify {
+		r = io.TeeReader(in, h)
+	}
+	_, err := io.Copy(progressWriter{w: out, p: p}, ctxReader{ctx: ctx, r: r})
+	return err
+}
+
+func copySymlink(src, dst string, info fs.FileInfo, opts Options) error {
+	p := opts.progress()
+	p.FileStarted(src, 0)
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return err
+	}
+	if err := copyMetadata(dst, info); err != nil {
+		return err
+	}
+	p.FileDone(src)
+	return nil
+}
@@ -0,0 +1,326 @@
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cfiler/internal/archive"
+)
+
+// extractor copies entries out of an archive. Archives are read front to
+// back in one pass, so directories are made as the first entry below them
+// arrives and their metadata is applied at the end, as in copier.
+type extractor struct {
+	ctx         context.Context
+	opts        Options
+	archivePath string
+	prefix      string // the entry being copied, "" for the whole archive
+	top         string // where prefix goes
+	root        string // nothing may be written outside this directory
+
+	dirs    map[string]string // archive directory to its destination, "" if skipped
+	inUse   map[string]bool   // destinations of dirs, which nothing may replace
+	created map[string]bool   // destination directories made by the extraction
+	made    []dirTask         // explicit directory entries, for their metadata
+	files   map[string]string // extracted files, for hard links to them
+}
+
+// Extract unpacks the archive src into dstDir, or into a new folder there
+// named after the archive with opts.Folder. src may also be an entry
+// inside an archive, which is copied out on its own. Entries that would
+// land outside the destination are refused.
+func Extract(ctx context.Context, src, dstDir string, opts Options) error {
+	if dstDir == "" {
+		return fmt.Errorf("no destination to extract %s to", src)
+	}
+	if err := localOnly("Extracting", dstDir); err != nil {
+		return err
+	}
+	archivePath, inner, ok := archive.Split(src)
+	if !ok {
+		return fmt.Errorf("not an archive: %s", src)
+	}
+	if inner != "" {
+		return copyArchived(ctx, archivePath, inner, filepath.Join(dstDir, path.Base(inner)), opts)
+	}
+	if opts.Folder {
+		dstDir = filepath.Join(dstDir, archive.Stem(filepath.Base(archivePath)))
+		if _, err := os.Lstat(dstDir); os.IsNotExist(err) {
+			if err := os.Mkdir(dstDir, 0755); err != nil {
+				return err
+			}
+			// The new folder is journaled as a whole.
+			opts.Batch.add(archivePath, dstDir)
+			opts.Batch = nil
+		}
+	}
+	return copyArchived(ctx, archivePath, "", dstDir, opts)
+}
+
+// MeasureArchives counts the files and bytes stored in archives, for the
+// progress totals of Extract.
+func MeasureArchives(paths []string) (files int, bytes int64) {
+	for _, p := range paths {
+		if archivePath, inner, ok := archive.Split(p); ok {
+			n, size := archive.Measure(archivePath, inner)
+			files += n
+			bytes += size
+		}
+	}
+	return files, bytes
+}
+
+// copyArchived copies the entry inner of an archive, with everything below
+// it, to dst. An empty inner copies the archive's contents into the
+// existing directory dst.
+func copyArchived(ctx context.Context, archivePath, inner, dst string, opts Options) error {
+	if _, err := archive.Stat(archivePath, inner); err != nil {
+		return err
+	}
+	x := &extractor{
+		ctx:         ctx,
+		opts:        opts,
+		archivePath: archivePath,
+		prefix:      inner,
+		top:         dst,
+		root:        filepath.Dir(dst),
+		dirs:        make(map[string]string),
+		inUse:       make(map[string]bool),
+		created:     make(map[string]bool),
+		files:       make(map[string]string),
+	}
+	if inner == "" {
+		x.root = dst
+		x.dirs[""] = dst
+	}
+
+	err := archive.Walk(archivePath, inner, func(e archive.Entry, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := x.entry(e, r); err != nil {
+			return opts.fail(x.src(e.Name), x.dst(e.Name), err)
+		}
+		return nil
+	})
+
+	return finishDirs(x.made, setMetadata, opts, err)
+}
+
+// src returns the path that names an archive entry in the UI and the
+// journal.
+func (x *extractor) src(name string) string {
+	return filepath.Join(x.archivePath, filepath.FromSlash(name))
+}
+
+// dst returns where the entry name was meant to go, if its directory is
+// known, for reporting failures.
+func (x *extractor) dst(name string) string {
+	if name == x.prefix {
+		return x.top
+	}
+	parent := path.Dir(name)
+	if parent == "." {
+		parent = ""
+	}
+	if dir := x.dirs[parent]; dir != "" && !archive.Unsafe(name) {
+		return filepath.Join(dir, filepath.FromSlash(path.Base(name)))
+	}
+	return ""
+}
+
+// target returns where the entry name goes, creating its parent
+// directories first. ok is false when a parent was skipped.
+func (x *extractor) target(name string) (dst string, ok bool, err error) {
+	if name == x.prefix {
+		return x.top, true, nil
+	}
+	if archive.Unsafe(name) {
+		return "", false, fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	parent := path.Dir(name)
+	if parent == "." {
+		parent = ""
+	}
+	dir, err := x.dir(parent)
+	if err != nil || dir == "" {
+		return "", false, err
+	}
+	dst = filepath.Join(dir, filepath.FromSlash(path.Base(name)))
+	if !within(x.root, dst) {
+		return "", false, fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	if err := x.noLinks(dir); err != nil {
+		return "", false, err
+	}
+	return dst, true, nil
+}
+
+// noLinks refuses a directory below the root that is, or is inside, a
+// symlink: within only looks at the path, and writing through a link could
+// land anywhere.
+func (x *extractor) noLinks(dir string) error {
+	for ; dir != x.root && within(x.root, dir); dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("unsafe path in archive: %s is not a directory", dir)
+		}
+	}
+	return nil
+}
+
+// dir returns the destination of the archive directory name, making it if
+// it is not there yet. An empty result means the directory was skipped.
+func (x *extractor) dir(name string) (string, error) {
+	if dst, ok := x.dirs[name]; ok {
+		return dst, nil
+	}
+	dst, err := x.makeDir(name)
+	// A directory that failed is reported once; its contents are left out.
+	x.dirs[name] = dst
+	if dst != "" {
+		x.inUse[dst] = true
+	}
+	return dst, err
+}
+
+func (x *extractor) makeDir(name string) (string, error) {
+	dst, ok, err := x.target(name)
+	if err != nil || !ok {
+		return "", err
+	}
+	info := archive.Entry{Name: name, Mode: fs.ModeDir | 0755}.Info()
+	dst, skip, merge, err := resolveDst(x.ctx, x.src(name), dst, info, x.opts)
+	if err != nil || skip {
+		return "", err
+	}
+	if !merge {
+		if err := os.Mkdir(dst, 0755); err != nil {
+			return "", err
+		}
+		x.record(name, dst)
+		x.created[dst] = true
+	}
+	return dst, nil
+}
+
+// record journals a new item unless it is inside a directory that is
+// already journaled as a whole.
+func (x *extractor) record(name, dst string) {
+	if !x.created[filepath.Dir(dst)] {
+		x.opts.Batch.add(x.src(name), dst)
+	}
+}
+
+func (x *extractor) entry(e archive.Entry, r io.Reader) error {
+	p := x.opts.progress()
+	if e.IsDir() {
+		dst, err := x.dir(e.Name)
+		if err == nil && x.created[dst] {
+			x.made = append(x.made, dirTask{dst: dst, info: e.Info()})
+		}
+		return err
+	}
+
+	dst, ok, err := x.target(e.Name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		accountArchived(x.src(e.Name), e, p)
+		return nil
+	}
+	if x.inUse[dst] {
+		// Replacing it, say with a symlink, would send the entries
+		// below it somewhere else.
+		return fmt.Errorf("archive entry replaces a directory it extracts into: %s", e.Name)
+	}
+	info := e.Info()
+	dst, skip, _, err := resolveDst(x.ctx, x.src(e.Name), dst, info, x.opts)
+	if err != nil {
+		return err
+	}
+	if skip {
+		accountArchived(x.src(e.Name), e, p)
+		return nil
+	}
+
+	p.FileStarted(x.src(e.Name), e.Size)
+	switch {
+	case e.Hardlink:
+		if target, ok := x.files[e.Link]; ok {
+			err = replaceWith(dst, func(tmp string) error { return os.Link(target, tmp) })
+			break
+		}
+		// The file it shares its data with is not being copied; copy
+		// that file's contents instead.
+		var rc io.ReadCloser
+		if rc, err = archive.Open(x.archivePath, e.Link); err == nil {
+			err = writeFile(x.ctx, dst, info, rc, x.opts)
+			rc.Close()
+		}
+	case e.Link != "":
+		err = replaceWith(dst, func(tmp string) error {
+			if err := os.Symlink(e.Link, tmp); err != nil {
+				return err
+			}
+			return lchtimes(tmp, e.ModTime, e.ModTime)
+		})
+	case r != nil:
+		err = writeFile(x.ctx, dst, info, r, x.opts)
+	}
+	if err != nil {
+		return err
+	}
+	x.files[e.Name] = dst
+	x.record(e.Name, dst)
+	p.FileDone(x.src(e.Name))
+	return nil
+}
+
+// replaceWith creates a link through create under a temporary name and
+// renames it over dst, which resolveDst left in place only if it was a
+// regular file to overwrite.
+func replaceWith(dst string, create func(tmp string) error) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return create(dst)
+	}
+	f, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	os.Remove(tmp)
+	if err := create(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// accountArchived reports an entry that is not written as done.
+func accountArchived(src string, e archive.Entry, p Progress) {
+	p.FileDone(src)
+	if e.Mode.IsRegular() && !e.Hardlink {
+		p.BytesDone(e.Size)
+	}
+}
+
+// within reports whether path is inside dir.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,120 @@
+package fileops
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTar(t *testing.T, path string, hdrs []*tar.Header) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, h := range hdrs {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Size > 0 {
+			if _, err := tw.Write(make([]byte, h.Size)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A symlink entry may not replace a directory the extraction has made, or
+// the entries after it would be written wherever it points.
+func TestExtractSymlinkOverDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	tmp := t.TempDir()
+	outside := filepath.Join(tmp, "outside")
+	dst := filepath.Join(tmp, "dst")
+	for _, d := range []string{outside, dst} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tarPath := filepath.Join(tmp, "evil.tar")
+	writeTar(t, tarPath, []*tar.Header{
+		{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "d", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "d/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+	})
+
+	var failed []string
+	opts := Options{
+		Resolve: func(context.Context, Conflict) (ConflictAction, error) {
+			return ConflictOverwrite, nil
+		},
+		OnError: func(src, _ string, err error) {
+			failed = append(failed, filepath.Base(src))
+		},
+	}
+	if err := Extract(context.Background(), tarPath, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(outside, "evil")); !os.IsNotExist(err) {
+		t.Errorf("wrote outside the destination: %v", err)
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "d")); err != nil || !info.IsDir() {
+		t.Errorf("d replaced: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "d", "evil")); err != nil {
+		t.Errorf("d/evil not extracted: %v", err)
+	}
+	if len(failed) != 1 || failed[0] != "d" {
+		t.Errorf("failed %v, want [d]", failed)
+	}
+}
+
+// Nothing is written through a symlink inside the destination, whatever
+// put it there.
+func TestExtractThroughSymlink(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	tmp := t.TempDir()
+	outside := filepath.Join(tmp, "outside")
+	dst := filepath.Join(tmp, "dst")
+	for _, d := range []string{outside, filepath.Join(dst, "d")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tarPath := filepath.Join(tmp, "evil.tar")
+	writeTar(t, tarPath, []*tar.Header{
+		{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "d/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+	})
+
+	x := &extractor{
+		ctx:         context.Background(),
+		archivePath: tarPath,
+		root:        dst,
+		dirs:        map[string]string{"": dst},
+		inUse:       make(map[string]bool),
+		created:     make(map[string]bool),
+		files:       make(map[string]string),
+	}
+	if _, err := x.dir("d"); err != nil {
+		t.Fatal(err)
+	}
+	// Swapped for a link after it was merged into.
+	if err := os.Remove(filepath.Join(dst, "d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dst, "d")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := x.target("d/evil"); err == nil {
+		t.Error("target through a symlink allowed")
+	}
+}
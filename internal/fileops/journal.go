@@ -0,0 +1,418 @@
+package fileops
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cfiler/internal/archive"
+	"cfiler/internal/config"
+	"cfiler/internal/trash"
+	"cfiler/internal/vfs"
+)
+
+const (
+	journalFile   = "journal.json"
+	maxJournalOps = 50
+	maxJournalAge = 7 * 24 * time.Hour
+)
+
+type OpKind string
+
+const (
+	OpRename  OpKind = "rename"
+	OpMove    OpKind = "move"
+	OpMkdir   OpKind = "mkdir"
+	OpCreate  OpKind = "create"
+	OpCopy    OpKind = "copy"
+	OpTrash   OpKind = "trash"
+	OpSymlink OpKind = "symlink"
+	OpLink    OpKind = "link"
+	OpExtract OpKind = "extract"
+)
+
+// Step is one path change of an operation: From became To. For mkdir and
+// create only To is set; for trash To is the item's location inside the trash. For
+// links To is the new link and From what it refers to, for symlinks as
+// written in the link. For copies and extractions Sum fingerprints the
+// tree made at To, so undo can tell whether it has been changed since.
+type Step struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	Sum  string `json:"sum,omitempty"`
+}
+
+// Op is a journaled operation that can be undone and redone as a unit.
+type Op struct {
+	Kind  OpKind    `json:"kind"`
+	Steps []Step    `json:"steps"`
+	Time  time.Time `json:"time"`
+}
+
+type journal struct {
+	Undo []Op `json:"undo"`
+	Redo []Op `json:"redo"`
+}
+
+// journalMu serializes access to the journal file between jobs.
+var journalMu sync.Mutex
+
+func loadJournal() (journal, error) {
+	var j journal
+	dir, err := config.Dir()
+	if err != nil {
+		return j, err
+	}
+	path := filepath.Join(dir, journalFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return j, err
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		// A damaged journal would otherwise fail every operation from
+		// now on. It is kept aside for inspection and history restarts.
+		if err := os.Rename(path, path+".bad"); err != nil {
+			return journal{}, err
+		}
+		return journal{}, nil
+	}
+
+	// Only recent operations survive a restart.
+	cutoff := time.Now().Add(-maxJournalAge)
+	recent := j.Undo[:0]
+	for _, op := range j.Undo {
+		if op.Time.After(cutoff) {
+			recent = append(recent, op)
+		}
+	}
+	j.Undo = recent
+	return j, nil
+}
+
+func saveJournal(j journal) error {
+	dir, err := config.Dir()
+	if err != nil {
+		return err
+	}
+	if len(j.Undo) > maxJournalOps {
+		j.Undo = j.Undo[len(j.Undo)-maxJournalOps:]
+	}
+	if len(j.Redo) > maxJournalOps {
+		j.Redo = j.Redo[len(j.Redo)-maxJournalOps:]
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written in full next to the journal and renamed over it, so a crash
+	// or a full disk leaves the previous journal rather than half of one.
+	f, err := os.CreateTemp(dir, journalFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, journalFile))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// record appends op to the undo history. A new operation invalidates
+// everything that could have been redone.
+func record(op Op) error {
+	if len(op.Steps) == 0 {
+		return nil
+	}
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	j, err := loadJournal()
+	if err != nil {
+		return err
+	}
+	op.Time = time.Now()
+	j.Undo = append(j.Undo, op)
+	j.Redo = nil
+	return saveJournal(j)
+}
+
+// Batch collects the steps of one user-visible operation, such as a paste
+// of several items, so it is undone as a whole.
+type Batch struct {
+	mu sync.Mutex
+	op Op
+}
+
+func NewBatch(kind OpKind) *Batch {
+	return &Batch{op: Op{Kind: kind}}
+}
+
+func (b *Batch) add(from, to string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.op.Steps = append(b.op.Steps, Step{From: from, To: to})
+}
+
+// Commit writes the collected steps to the journal.
+func (b *Batch) Commit() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.op.Kind == OpCopy || b.op.Kind == OpExtract {
+		for i := range b.op.Steps {
+			b.op.Steps[i].Sum = treeSum(b.op.Steps[i].To)
+		}
+	}
+	return record(b.op)
+}
+
+// Undo reverts the most recent operation and makes it available to Redo.
+func Undo(ctx context.Context) (Op, error) {
+	return replay(ctx, true)
+}
+
+// Redo re-applies the most recently undone operation.
+func Redo(ctx context.Context) (Op, error) {
+	return replay(ctx, false)
+}
+
+func replay(ctx context.Context, undo bool) (Op, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	j, err := loadJournal()
+	if err != nil {
+		return Op{}, err
+	}
+
+	from := &j.Redo
+	if undo {
+		from = &j.Undo
+	}
+	if len(*from) == 0 {
+		if undo {
+			return Op{}, fmt.Errorf("nothing to undo")
+		}
+		return Op{}, fmt.Errorf("nothing to redo")
+	}
+	op := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+
+	if undo {
+		err = undoOp(ctx, &op)
+	} else {
+		err = redoOp(ctx, &op)
+	}
+	// A failed replay is dropped rather than retried forever; the steps
+	// that did succeed cannot be told apart from the rest.
+	if err == nil {
+		if undo {
+			j.Redo = append(j.Redo, op)
+		} else {
+			j.Undo = append(j.Undo, op)
+		}
+	}
+	if saveErr := saveJournal(j); err == nil {
+		err = saveErr
+	}
+	return op, err
+}
+
+func undoOp(ctx context.Context, op *Op) error {
+	if op.Kind == OpCopy || op.Kind == OpExtract {
+		// Nothing is removed unless every tree is as it was made.
+		for _, s := range op.Steps {
+			if err := unchanged(s); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(op.Steps) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := op.Steps[i]
+		var err error
+		switch op.Kind {
+		case OpRename, OpMove:
+			err = moveBack(ctx, s.To, s.From)
+		case OpMkdir:
+			err = vfs.Remove(s.To)
+		case OpCreate:
+			err = removeEmpty(s.To)
+		case OpCopy, OpExtract:
+			err = vfs.RemoveAll(s.To)
+		case OpSymlink, OpLink:
+			err = os.Remove(s.To)
+		case OpTrash:
+			var item trash.Item
+			if item, err = trash.Lookup(s.To); err == nil {
+				if err = moveBack(ctx, s.To, s.From); err == nil {
+					err = trash.Forget(item)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func redoOp(ctx context.Context, op *Op) error {
+	for i, s := range op.Steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		switch op.Kind {
+		case OpRename, OpMove:
+			err = moveBack(ctx, s.From, s.To)
+		case OpMkdir:
+			err = vfs.Mkdir(s.To, 0755)
+		case OpCreate:
+			var f io.WriteCloser
+			if f, err = vfs.Create(s.To, 0666); err == nil {
+				err = f.Close()
+			}
+		case OpCopy:
+			err = copyTo(ctx, s.From, s.To, Options{})
+			op.Steps[i].Sum = treeSum(s.To)
+		case OpExtract:
+			// From is the archive for a folder it was unpacked into, and
+			// an entry inside it otherwise. Entries that were refused the
+			// first time are passed over again, as the job did.
+			var first error
+			opts := Options{Folder: true, OnError: func(_, _ string, err error) {
+				if first == nil {
+					first = err
+				}
+			}}
+			if archivePath, inner, ok := archive.Split(s.From); !ok {
+				err = fmt.Errorf("not an archive: %s", s.From)
+			} else if inner == "" {
+				err = Extract(ctx, s.From, filepath.Dir(s.To), opts)
+			} else {
+				err = copyArchived(ctx, archivePath, inner, s.To, opts)
+			}
+			if err == nil {
+				err = first
+			}
+			op.Steps[i].Sum = treeSum(s.To)
+		case OpSymlink:
+			err = os.Symlink(s.From, s.To)
+		case OpLink:
+			err = os.Link(s.From, s.To)
+		case OpTrash:
+			var item trash.Item
+			if item, err = trash.Put(s.From); err == nil {
+				// The trash may pick a different name this time.
+				op.Steps[i].To = item.Path()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEmpty removes the file created at path, unless something has been
+// written to it since; undo must not throw away the user's work.
+func removeEmpty(path string) error {
+	info, err := vfs.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() > 0 {
+		return fmt.Errorf("file has been changed since it was created: %s", path)
+	}
+	return vfs.Remove(path)
+}
+
+// unchanged reports an error unless the tree made by step s still matches
+// its fingerprint; undo must not throw away what was put in it since.
+func unchanged(s Step) error {
+	if _, err := vfs.Lstat(s.To); errors.Is(err, fs.ErrNotExist) {
+		return nil // already gone
+	}
+	if s.Sum == "" || treeSum(s.To) != s.Sum {
+		return fmt.Errorf("%s has been changed since it was made; remove it by hand", s.To)
+	}
+	return nil
+}
+
+// treeSum fingerprints the names and types of everything at and below
+// path, and the sizes and modification times of all but directories, whose
+// times change as entries come and go. It is empty if path cannot be read.
+func treeSum(path string) string {
+	h := sha256.New()
+	var walk func(p, rel string, info fs.FileInfo) error
+	walk = func(p, rel string, info fs.FileInfo) error {
+		if !info.IsDir() {
+			fmt.Fprintf(h, "%q %v %d %d\n", rel, info.Mode(), info.Size(), info.ModTime().UnixNano())
+			return nil
+		}
+		fmt.Fprintf(h, "%q %v\n", rel, info.Mode())
+		infos, err := vfs.ReadDir(p)
+		if err != nil {
+			return err
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+		for _, child := range infos {
+			if err := walk(vfs.Join(p, child.Name()), rel+"/"+child.Name(), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	info, err := vfs.Lstat(path)
+	if err == nil {
+		err = walk(path, ".", info)
+	}
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// moveBack renames src to dst without overwriting anything at dst.
+func moveBack(ctx context.Context, src, dst string) error {
+	if _, err := vfs.Lstat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %s", dst)
+	}
+	if err := vfs.MkdirAll(vfs.Dir(dst), 0755); err != nil {
+		return err
+	}
+	info, err := vfs.Lstat(src)
+	if err != nil {
+		return err
+	}
+	return moveItem(ctx, src, dst, info, Options{})
+}
@@ -0,0 +1,50 @@
+package fileops
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A journal that was cut short is set aside, and undo keeps working for
+// what is recorded after it.
+func TestDamagedJournal(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	dir := t.TempDir()
+	if err := Mkdir(dir, "first"); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(config, "*", journalFile))
+	if len(matches) != 1 {
+		t.Fatalf("found journals %v", matches)
+	}
+	path := matches[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Mkdir(dir, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".bad"); err != nil {
+		t.Errorf("damaged journal not kept: %v", err)
+	}
+	if _, err := Undo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "second")); !os.IsNotExist(err) {
+		t.Errorf("second not undone: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "first")); err != nil {
+		t.Errorf("first, from the damaged history, was touched: %v", err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp")); len(tmps) > 0 {
+		t.Errorf("left temporary files %v", tmps)
+	}
+}
@@ -0,0 +1,96 @@
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Symlink creates a symbolic link to src in dstDir, named like src. With
+// opts.Relative the link points at src relative to dstDir. An existing
+// destination is handled through opts.Resolve like a copy.
+func Symlink(ctx context.Context, src, dstDir string, opts Options) error {
+	return makeLink(ctx, src, dstDir, opts, func(dst string) (string, error) {
+		target := src
+		if opts.Relative {
+			rel, err := filepath.Rel(filepath.Dir(dst), src)
+			if err != nil {
+				return "", err
+			}
+			target = rel
+		}
+		return target, os.Symlink(target, dst)
+	})
+}
+
+// Link creates a hard link to src in dstDir, named like src. Directories
+// cannot be hard linked.
+func Link(ctx context.Context, src, dstDir string, opts Options) error {
+	if info, err := os.Lstat(src); err == nil && info.IsDir() {
+		return fmt.Errorf("cannot hard link a directory: %s", src)
+	}
+	return makeLink(ctx, src, dstDir, opts, func(dst string) (string, error) {
+		return src, os.Link(src, dst)
+	})
+}
+
+// makeLink resolves the destination for a new link and calls create for
+// it. create returns what the link refers to, which is what the journal
+// needs to create it again.
+func makeLink(ctx context.Context, src, dstDir string, opts Options, create func(dst string) (string, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := localOnly("Links", src); err != nil {
+		return err
+	}
+	if err := localOnly("Links", dstDir); err != nil {
+		return err
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	dst, skip, err := resolveLinkDst(ctx, src, filepath.Join(dstDir, filepath.Base(src)), info, opts)
+	if err != nil {
+		return err
+	}
+	p := opts.progress()
+	if !skip {
+		p.FileStarted(src, 0)
+		target, err := create(dst)
+		if err != nil {
+			return err
+		}
+		opts.Batch.add(target, dst)
+	}
+	// Account for everything below src so progress totals add up.
+	accountTree(src, p)
+	return nil
+}
+
+// resolveLinkDst is resolveDst for links, which never merge into an
+// existing directory and cannot replace a file by renaming over it.
+func resolveLinkDst(ctx context.Context, src, dst string, srcInfo fs.FileInfo, opts Options) (string, bool, error) {
+	dstInfo, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return dst, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	path, skip, err := resolveExisting(ctx, src, dst, srcInfo, dstInfo, opts)
+	if err != nil || skip {
+		return "", skip, err
+	}
+	if path == dst {
+		// Left in place for a rename that links do not do.
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return "", false, err
+		}
+	}
+	return path, false, nil
+}
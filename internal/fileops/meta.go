@@ -0,0 +1,25 @@
+package fileops
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// copyMetadata applies the ownership, permissions and times of info to
+// dst. Ownership is best effort: only root may give files away.
+func copyMetadata(dst string, info fs.FileInfo) error {
+	if uid, gid, ok := owner(info); ok {
+		if err := os.Lchown(dst, uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+			return err
+		}
+	}
+	// chown clears setuid/setgid, so the mode has to come after it.
+	// Symlinks have no permissions of their own.
+	if info.Mode()&fs.ModeSymlink == 0 {
+		if err := os.Chmod(dst, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+			return err
+		}
+	}
+	return lchtimes(dst, atime(info), info.ModTime())
+}
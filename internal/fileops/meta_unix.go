@@ -0,0 +1,27 @@
+//go:build !windows
+
+package fileops
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// lchtimes sets the times of path without following a final symlink.
+func lchtimes(path string, atime, mtime time.Time) error {
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, []unix.Timespec{
+		unix.NsecToTimespec(atime.UnixNano()),
+		unix.NsecToTimespec(mtime.UnixNano()),
+	}, unix.AT_SYMLINK_NOFOLLOW)
+}
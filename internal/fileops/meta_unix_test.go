@@ -0,0 +1,60 @@
+//go:build !windows
+
+package fileops
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLchtimesKeepsNanoseconds(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	link := filepath.Join(dir, "link")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Unix(1700000000, 123456289)
+	for _, p := range []string{file, link} {
+		if err := lchtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.ModTime().UnixNano(); got != mtime.UnixNano() {
+			t.Errorf("%s: mtime %d, want %d", filepath.Base(p), got, mtime.UnixNano())
+		}
+	}
+}
+
+func TestCopyKeepsNanoseconds(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	file := filepath.Join(src, "file")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1700000000, 123456289)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Copy(context.Background(), file, dst, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(filepath.Join(dst, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.ModTime().UnixNano(); got != mtime.UnixNano() {
+		t.Errorf("copied mtime %d, want %d", got, mtime.UnixNano())
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package fileops
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+func owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+func lchtimes(path string, atime, mtime time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	return os.Chtimes(path, atime, mtime)
+}
@@ -1,8 +1,10 @@
 package fileops
 
 import (
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 )
 
 // OpenFile opens a file with the OS-associated application.
@@ -16,3 +18,17 @@ func OpenFile(path string) error {
 		return exec.Command("xdg-open", path).Start()
 	}
 }
+
+// EditCommand returns the command that opens path in the user's editor:
+// $VISUAL or $EDITOR, falling back to the platform's basic editor.
+func EditCommand(path string) *exec.Cmd {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return exec.Command(fields[0], append(fields[1:], path)...)
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return exec.Command("notepad", path)
+	}
+	return exec.Command("vi", path)
+}
@@ -1,123 +1,496 @@
 package fileops
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+
+	"cfiler/internal/archive"
+	"cfiler/internal/trash"
+	"cfiler/internal/vfs"
 )
 
-func Copy(src, dstDir string) error {
-	info, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
+// Progress receives notifications while an operation runs. Implementations
+// must be safe for concurrent use.
+type Progress interface {
+	FileStarted(path string, size int64)
+	BytesDone(n int64)
+	FileDone(path string)
+}
+
+// Options controls how Copy, Move and Delete behave.
+type Options struct {
+	Progress Progress
+	Resolve  Resolver
+	Batch    *Batch // journal for undo; nil to leave the operation unrecorded
+
+	// Dereference copies the targets of symlinks instead of the links.
+	Dereference bool
+
+	// Verify reads every copied file back and compares its SHA-256 with
+	// the source. A mismatch fails the file with ErrChecksum, and a move
+	// keeps the source.
+	Verify bool
+
+	// Clone tries a copy-on-write clone for every file, falling back to
+	// copying the data. Without it files are only cloned on filesystems
+	// known to support it. Verify turns cloning off. Copies to or from
+	// anywhere but the local disk fail rather than copy the data.
+	Clone bool
+
+	// Relative makes Symlink create links relative to their directory.
+	Relative bool
 
-	dstPath := filepath.Join(dstDir, filepath.Base(src))
+	// Attrs is the change SetAttrs makes.
+	Attrs *Attrs
 
-	if info.IsDir() {
-		return copyDir(src, dstPath)
+	// Folder makes Extract unpack into a new folder named after the
+	// archive.
+	Folder bool
+
+	// OnError, when set, receives per-item failures inside directories and
+	// the operation carries on with the next item. dst is empty for
+	// operations without a destination. Without it the first error aborts.
+	OnError func(src, dst string, err error)
+}
+
+type nopProgress struct{}
+
+func (nopProgress) FileStarted(string, int64) {}
+func (nopProgress) BytesDone(int64)           {}
+func (nopProgress) FileDone(string)           {}
+
+func (o Options) progress() Progress {
+	if o.Progress == nil {
+		return nopProgress{}
 	}
-	return copyFile(src, dstPath)
+	return o.Progress
 }
 
-func Move(src, dstDir string) error {
-	dstPath := filepath.Join(dstDir, filepath.Base(src))
+// fail hands err to OnError and returns nil so the caller continues, or
+// returns err unchanged if there is no OnError or the context ended.
+func (o Options) fail(src, dst string, err error) error {
+	if o.OnError == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	o.OnError(src, dst, err)
+	return nil
+}
 
-	err := os.Rename(src, dstPath)
-	if err != nil {
-		// Cross-volume: copy + delete
-		info, statErr := os.Stat(src)
-		if statErr != nil {
-			return statErr
-		}
-		if info.IsDir() {
-			if copyErr := copyDir(src, dstPath); copyErr != nil {
-				return copyErr
+func (o Options) stat(path string) (fs.FileInfo, error) {
+	if o.Dereference {
+		return vfs.Stat(path)
+	}
+	return vfs.Lstat(path)
+}
+
+// Measure counts the files and bytes below paths, for progress totals.
+func Measure(paths []string) (files int, bytes int64) {
+	for _, p := range paths {
+		if archivePath, inner, ok := archive.Split(p); ok && inner != "" {
+			n, size := archive.Measure(archivePath, inner)
+			files += n
+			bytes += size
+			continue
+		}
+		if !vfs.IsLocal(p) {
+			n, size := measureTree(p)
+			files += n
+			bytes += size
+			continue
+		}
+		_ = filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
 			}
-		} else {
-			if copyErr := copyFile(src, dstPath); copyErr != nil {
-				return copyErr
+			files++
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				bytes += info.Size()
 			}
-		}
-		return os.RemoveAll(src)
+			return nil
+		})
 	}
-	return nil
+	return files, bytes
 }
 
-func Delete(path string) error {
-	return os.RemoveAll(path)
+// measureTree is Measure for locations that are not on the local disk.
+func measureTree(p string) (files int, bytes int64) {
+	info, err := vfs.Lstat(p)
+	if err != nil {
+		return 0, 0
+	}
+	if !info.IsDir() {
+		if info.Mode().IsRegular() {
+			return 1, info.Size()
+		}
+		return 1, 0
+	}
+	infos, _ := vfs.ReadDir(p)
+	for _, info := range infos {
+		n, size := measureTree(vfs.Join(p, info.Name()))
+		files += n
+		bytes += size
+	}
+	return files, bytes
 }
 
-func Rename(oldPath, newName string) error {
-	dir := filepath.Dir(oldPath)
-	newPath := filepath.Join(dir, newName)
-	return os.Rename(oldPath, newPath)
+// Copy copies src into dstDir, which may be on a different backend. src
+// may also be an entry inside an archive. Cancelling ctx stops the copy;
+// the file being written at that moment is removed. Existing destinations
+// are handled through opts.Resolve.
+func Copy(ctx context.Context, src, dstDir string, opts Options) error {
+	return copyTo(ctx, src, vfs.Join(dstDir, vfs.Base(src)), opts)
 }
 
-func Mkdir(parentDir, name string) error {
-	path := filepath.Join(parentDir, name)
-	return os.MkdirAll(path, 0755)
+// copyTo copies src to dst the way that suits their backends: archives are
+// unpacked in one pass and local copies use the fast paths of copier.
+func copyTo(ctx context.Context, src, dst string, opts Options) error {
+	local := vfs.IsLocal(vfs.Dir(dst))
+	if opts.Clone && (!local || !vfs.IsLocal(src)) {
+		return errNoClone(src, dst)
+	}
+	if archivePath, inner, ok := archive.Split(src); ok && inner != "" && local {
+		return copyArchived(ctx, archivePath, inner, dst, opts)
+	}
+	info, err := opts.stat(src)
+	if err != nil {
+		return err
+	}
+	if !local || !vfs.IsLocal(src) {
+		return transferItem(ctx, src, dst, info, opts)
+	}
+	return copyItem(ctx, src, dst, info, opts)
 }
 
-func copyFile(src, dst string) error {
-	// Don't overwrite existing
-	if _, err := os.Stat(dst); err == nil {
-		return fmt.Errorf("destination already exists: %s", dst)
+// Move moves src into dstDir, resolving existing destinations the same way
+// as Copy. When it falls back to copying, the source is only removed once
+// the copy has finished without being cancelled.
+func Move(ctx context.Context, src, dstDir string, opts Options) error {
+	info, err := vfs.Lstat(src)
+	if err != nil {
+		return err
 	}
+	return moveItem(ctx, src, vfs.Join(dstDir, vfs.Base(src)), info, opts)
+}
 
-	in, err := os.Open(src)
+// Duplicate copies src next to itself under the first free name of the
+// form "name (copy).ext", "name (copy 2).ext" and so on.
+func Duplicate(ctx context.Context, src string, opts Options) error {
+	info, err := opts.stat(src)
 	if err != nil {
 		return err
 	}
-	defer in.Close()
+	return copyTo(ctx, src, duplicateName(src, info.IsDir()), opts)
+}
+
+func duplicateName(path string, isDir bool) string {
+	dir := vfs.Dir(path)
+	stem := vfs.Base(path)
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(stem)
+		// Dotfiles have no extension, and "x.tar.gz" keeps ".tar.gz".
+		if ext == stem {
+			ext = ""
+		} else if inner := filepath.Ext(strings.TrimSuffix(stem, ext)); inner == ".tar" {
+			ext = inner + ext
+		}
+		stem = strings.TrimSuffix(stem, ext)
+	}
+	for i := 1; ; i++ {
+		suffix := " (copy)"
+		if i > 1 {
+			suffix = fmt.Sprintf(" (copy %d)", i)
+		}
+		candidate := vfs.Join(dir, stem+suffix+ext)
+		if _, err := vfs.Lstat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
+
+func moveItem(ctx context.Context, src, dst string, info fs.FileInfo, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p := opts.progress()
 
-	out, err := os.Create(dst)
+	dst, skip, merge, err := resolveDst(ctx, src, dst, info, opts)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	if skip {
+		accountTree(src, p)
+		return nil
+	}
+	if merge {
+		infos, err := vfs.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, childInfo := range infos {
+			childSrc := vfs.Join(src, childInfo.Name())
+			childDst := vfs.Join(dst, childInfo.Name())
+			if err := moveItem(ctx, childSrc, childDst, childInfo, opts); err != nil {
+				if err := opts.fail(childSrc, childDst, err); err != nil {
+					return err
+				}
+			}
+		}
+		// Skipped or failed children stay behind, and so does their
+		// directory.
+		if rest, _ := vfs.ReadDir(src); len(rest) > 0 {
+			return nil
+		}
+		return vfs.Remove(src)
+	}
+
+	if vfs.Same(src, dst) {
+		if err := vfs.Rename(src, dst); err == nil {
+			// A rename moves everything at once; account for it in one step.
+			accountTree(dst, p)
+			opts.Batch.add(src, dst)
+			return nil
+		}
+	}
 
-	if _, err := io.Copy(out, in); err != nil {
+	// Across volumes or backends: copy + delete. Sources that failed to
+	// copy are kept.
+	var mu sync.Mutex
+	failed := make(map[string]bool)
+	copyOpts := opts
+	if opts.OnError != nil {
+		copyOpts.OnError = func(s, d string, err error) {
+			mu.Lock()
+			failed[s] = true
+			mu.Unlock()
+			opts.OnError(s, d, err)
+		}
+	}
+	copyTree := copyNode
+	if !vfs.IsLocal(src) || !vfs.IsLocal(dst) {
+		if opts.Clone {
+			return errNoClone(src, dst)
+		}
+		copyTree = transferNode
+	}
+	if err := copyTree(ctx, src, dst, info, copyOpts); err != nil {
 		return err
 	}
+	if len(failed) > 0 {
+		removeExcept(src, failed)
+		return nil
+	}
+	if err := vfs.RemoveAll(src); err != nil {
+		return err
+	}
+	opts.Batch.add(src, dst)
+	return nil
+}
+
+// removeExcept removes path and everything below it apart from the paths
+// in keep and the directories containing them.
+func removeExcept(path string, keep map[string]bool) {
+	if keep[path] {
+		return
+	}
+	if infos, err := vfs.ReadDir(path); err == nil {
+		for _, info := range infos {
+			removeExcept(vfs.Join(path, info.Name()), keep)
+		}
+	}
+	// Fails harmlessly for directories that still hold kept entries.
+	vfs.Remove(path)
+}
+
+func Delete(ctx context.Context, path string, opts Options) error {
+	return removeTree(ctx, path, opts)
+}
 
-	info, err := os.Stat(src)
+// Trash moves path to the trash. Progress is reported for the files it
+// contains, although the move itself is a single rename.
+func Trash(ctx context.Context, path string, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := localOnly("The trash", path); err != nil {
+		return err
+	}
+	files, bytes := Measure([]string{path})
+	item, err := trash.Put(path)
 	if err != nil {
-		return nil
+		return err
+	}
+	opts.Batch.add(item.OrigPath, item.Path())
+	p := opts.progress()
+	for i := 0; i < files; i++ {
+		p.FileDone(path)
 	}
-	return os.Chmod(dst, info.Mode())
+	p.BytesDone(bytes)
+	return nil
 }
 
-func copyDir(src, dst string) error {
-	srcInfo, err := os.Stat(src)
+// Restore moves a trashed item, given by its location below the trash's
+// files/ directory, back to where it was deleted from. An existing file at
+// the original location is handled through opts.Resolve.
+func Restore(ctx context.Context, trashedPath string, opts Options) error {
+	item, err := trash.Lookup(trashedPath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(trashedPath)
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(item.OrigPath), 0755); err != nil {
+		return err
+	}
+	if err := moveItem(ctx, trashedPath, item.OrigPath, info, opts); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(trashedPath); os.IsNotExist(err) {
+		return trash.Forget(item)
+	}
+	return nil
+}
 
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
+func Rename(oldPath, newName string) error {
+	dir := vfs.Dir(oldPath)
+	newPath := vfs.Join(dir, newName)
+	if err := vfs.Rename(oldPath, newPath); err != nil {
 		return err
 	}
+	// The journal is best effort; a failure there must not look like a
+	// failed rename.
+	_ = record(Op{Kind: OpRename, Steps: []Step{{From: oldPath, To: newPath}}})
+	return nil
+}
+
+// RenameAll performs the renames in steps in order, without overwriting
+// anything, and journals them as one operation. It stops at the first
+// failure; the renames done by then stay and can be undone.
+func RenameAll(steps []Step) error {
+	b := NewBatch(OpRename)
+	var err error
+	for _, s := range steps {
+		if err = renameNoReplace(s.From, s.To); err != nil {
+			break
+		}
+		b.add(s.From, s.To)
+	}
+	if len(b.op.Steps) > 0 {
+		_ = b.Commit()
+	}
+	return err
+}
 
-	entries, err := os.ReadDir(src)
+// renameNoReplace renames oldPath to newPath unless newPath is taken by
+// another file. A change of case only is allowed on filesystems that
+// ignore case.
+func renameNoReplace(oldPath, newPath string) error {
+	if info, err := vfs.Lstat(newPath); err == nil {
+		oldInfo, err := vfs.Lstat(oldPath)
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(info, oldInfo) {
+			return fmt.Errorf("destination already exists: %s", newPath)
+		}
+	}
+	return vfs.Rename(oldPath, newPath)
+}
+
+func Mkdir(parentDir, name string) error {
+	path := vfs.Join(parentDir, name)
+	_, statErr := vfs.Stat(path)
+	if err := vfs.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	if errors.Is(statErr, fs.ErrNotExist) {
+		_ = record(Op{Kind: OpMkdir, Steps: []Step{{To: path}}})
+	}
+	return nil
+}
+
+// CreateFile creates an empty file called name in parentDir. An existing
+// file is left alone and reported as an error.
+func CreateFile(parentDir, name string) error {
+	if name == "" {
+		return errors.New("no file name given")
+	}
+	path := vfs.Join(parentDir, name)
+	f, err := vfs.Create(path, 0666)
 	if err != nil {
 		return err
 	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	_ = record(Op{Kind: OpCreate, Steps: []Step{{To: path}}})
+	return nil
+}
 
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
+// removeTree removes path and everything below it, reporting each file.
+func removeTree(ctx context.Context, path string, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p := opts.progress()
+	info, err := vfs.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		p.FileStarted(path, 0)
+		if err := vfs.Remove(path); err != nil {
+			return err
+		}
+		p.FileDone(path)
+		return nil
+	}
 
-		if entry.IsDir() {
-			if err := copyDir(srcPath, dstPath); err != nil {
-				return err
-			}
-		} else {
-			if err := copyFile(srcPath, dstPath); err != nil {
+	infos, err := vfs.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		child := vfs.Join(path, info.Name())
+		if err := removeTree(ctx, child, opts); err != nil {
+			if err := opts.fail(child, "", err); err != nil {
 				return err
 			}
 		}
 	}
+	if err := vfs.Remove(path); err != nil {
+		// Entries that could not be deleted have already been reported.
+		if rest, _ := vfs.ReadDir(path); len(rest) > 0 && opts.OnError != nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
 
+// localOnly fails for paths that are not on the local disk, for the
+// operations that only work there.
+func localOnly(what, path string) error {
+	if !vfs.IsLocal(path) {
+		return fmt.Errorf("%s only works on the local disk: %s", what, path)
+	}
 	return nil
 }
+
+// errNoClone refuses a copy-on-write clone that is not between two places
+// on the local disk, rather than quietly copying the data.
+func errNoClone(src, dst string) error {
+	path := src
+	if vfs.IsLocal(src) {
+		path = dst
+	}
+	return localOnly("Cloning", path)
+}
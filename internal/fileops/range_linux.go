@@ -0,0 +1,46 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// rangeChunk is how much copy_file_range copies per call, which is also
+// how often progress and cancellation are checked.
+const rangeChunk = 8 << 20
+
+// copyRange copies in to out with copy_file_range, keeping the data inside
+// the kernel. ok is false when the filesystems do not support it and
+// nothing was copied, so the caller should fall back to reading and
+// writing.
+func copyRange(ctx context.Context, out, in *os.File, p Progress) (ok bool, err error) {
+	var done int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+		n, err := unix.CopyFileRange(int(in.Fd()), nil, int(out.Fd()), nil, rangeChunk, 0)
+		if err != nil {
+			if done == 0 && rangeUnsupported(err) {
+				return false, nil
+			}
+			return true, err
+		}
+		if n == 0 {
+			// Some pseudo filesystems report a size of zero and read
+			// as empty through copy_file_range; read those normally.
+			return done > 0, nil
+		}
+		done += int64(n)
+		p.BytesDone(int64(n))
+	}
+}
+
+func rangeUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EXDEV) ||
+		errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.EPERM)
+}
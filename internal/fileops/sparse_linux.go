@@ -0,0 +1,88 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// copySparse copies in to out one data segment at a time, found with
+// SEEK_DATA and SEEK_HOLE, and leaves holes where in has them. Hole bytes
+// are fed to sum as zeros when it is not nil. ok is false when in is not
+// sparse or its filesystem cannot report holes.
+func copySparse(ctx context.Context, out, in *os.File, size int64, sum io.Writer, p Progress) (ok bool, err error) {
+	if !isSparse(in, size) {
+		return false, nil
+	}
+	fd := int(in.Fd())
+	var off int64
+	for off < size {
+		data, err := unix.Seek(fd, off, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// Nothing but a hole up to the end.
+			data = size
+		} else if err != nil {
+			if off == 0 {
+				return false, nil
+			}
+			return true, err
+		}
+		if err := skipHole(sum, data-off, p); err != nil {
+			return true, err
+		}
+		if data >= size {
+			break
+		}
+
+		hole, err := unix.Seek(fd, data, unix.SEEK_HOLE)
+		if err != nil {
+			return true, err
+		}
+		var r io.Reader = io.NewSectionReader(in, data, hole-data)
+		if sum != nil {
+			r = io.TeeReader(r, sum)
+		}
+		w := progressWriter{w: io.NewOffsetWriter(out, data), p: p}
+		if _, err := io.Copy(w, ctxReader{ctx: ctx, r: r}); err != nil {
+			return true, err
+		}
+		off = hole
+	}
+	// Extending the file creates the trailing hole.
+	return true, out.Truncate(size)
+}
+
+// isSparse reports whether f occupies fewer blocks than its size needs.
+func isSparse(f *os.File, size int64) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Blocks*512 < size
+}
+
+// skipHole accounts for n bytes of hole that are not written.
+func skipHole(sum io.Writer, n int64, p Progress) error {
+	if n <= 0 {
+		return nil
+	}
+	if sum != nil {
+		if _, err := io.CopyN(sum, zeroReader{}, n); err != nil {
+			return err
+		}
+	}
+	p.BytesDone(n)
+	return nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package fileops
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// copySparse is only implemented on Linux; elsewhere holes are written out
+// as zeros.
+func copySparse(ctx context.Context, out, in *os.File, size int64, sum io.Writer, p Progress) (ok bool, err error) {
+	return false, nil
+}
@@ -0,0 +1,90 @@
+package fileops
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfiler/internal/vfs"
+)
+
+// Files are copied under a temporary name in the destination directory and
+// renamed into place once complete, so a crash leaves one of these behind
+// rather than a truncated file under the real name.
+const (
+	tempPrefix = ".cfiler-"
+	tempSuffix = ".part"
+)
+
+// staleTempAge is how long an unfinished copy has to have gone unwritten
+// before it is taken for the leftover of an interrupted run, rather than a
+// file another job or another cfiler is still writing.
+const staleTempAge = time.Hour
+
+// maxTempBase keeps temporary names within the usual 255 byte limit.
+const maxTempBase = 200
+
+// createTemp creates an empty temporary file next to dst.
+func createTemp(dst string) (*os.File, error) {
+	base := filepath.Base(dst)
+	if len(base) > maxTempBase {
+		base = base[:maxTempBase]
+	}
+	return os.CreateTemp(filepath.Dir(dst), tempPrefix+base+".*"+tempSuffix)
+}
+
+// IsTempName reports whether name looks like an unfinished copy.
+func IsTempName(name string) bool {
+	return strings.HasPrefix(name, tempPrefix) && strings.HasSuffix(name, tempSuffix) &&
+		len(name) > len(tempPrefix)+len(tempSuffix)
+}
+
+// IsStaleTemp reports whether name, last written at mtime, is an
+// unfinished copy left behind long enough ago to be removed.
+func IsStaleTemp(name string, mtime time.Time) bool {
+	return IsTempName(name) && time.Since(mtime) > staleTempAge
+}
+
+// CleanTemp removes the stale unfinished copies left in dir and returns how
+// many were removed.
+func CleanTemp(dir string) (int, error) {
+	infos, err := vfs.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || !IsStaleTemp(info.Name(), info.ModTime()) {
+			continue
+		}
+		if err := vfs.Remove(vfs.Join(dir, info.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// createTempVFS is createTemp for any backend.
+func createTempVFS(dst string) (io.WriteCloser, string, error) {
+	base := vfs.Base(dst)
+	if len(base) > maxTempBase {
+		base = base[:maxTempBase]
+	}
+	for i := 0; ; i++ {
+		tmp := vfs.Join(vfs.Dir(dst), tempPrefix+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+tempSuffix)
+		w, err := vfs.Create(tmp, 0600)
+		if err == nil {
+			return w, tmp, nil
+		}
+		if !errors.Is(err, fs.ErrExist) || i == 100 {
+			return nil, "", err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Unfinished copies that are still being written, by this process or
+// another, are left alone.
+func TestCleanTempKeepsRecent(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	files := map[string]time.Time{
+		".cfiler-old.txt.123.part":    old,
+		".cfiler-recent.txt.456.part": time.Now().Add(-time.Minute),
+		"old.txt":                     old,
+	}
+	for name, mtime := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		stale := IsStaleTemp(name, mtime)
+		if want := name == ".cfiler-old.txt.123.part"; stale != want {
+			t.Errorf("IsStaleTemp(%q) = %v, want %v", name, stale, want)
+		}
+	}
+
+	n, err := CleanTemp(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("removed %d files, want 1", n)
+	}
+	for name := range files {
+		_, err := os.Lstat(filepath.Join(dir, name))
+		if gone := os.IsNotExist(err); gone != (name == ".cfiler-old.txt.123.part") {
+			t.Errorf("%s removed: %v", name, gone)
+		}
+	}
+}
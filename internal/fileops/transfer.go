@@ -0,0 +1,135 @@
+package fileops
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+
+	"cfiler/internal/vfs"
+)
+
+const transferBufSize = 1 << 20
+
+// transfer copies a tree through the vfs backends, for copies where either
+// side is not on the local disk. It walks the tree like copier, but copies
+// one file at a time: remote backends are limited by their connection
+// rather than the disk.
+type transfer struct {
+	tree
+}
+
+// transferItem copies src to dst, consulting opts.Resolve if dst exists.
+func transferItem(ctx context.Context, src, dst string, info fs.FileInfo, opts Options) error {
+	t := newTransfer(ctx, opts)
+	return finishDirs(t.dirs, setMetadata, opts, t.item(src, dst, info, opts))
+}
+
+// transferNode copies src to a dst that does not exist yet.
+func transferNode(ctx context.Context, src, dst string, info fs.FileInfo, opts Options) error {
+	t := newTransfer(ctx, opts)
+	return finishDirs(t.dirs, setMetadata, opts, t.node(src, dst, info, opts))
+}
+
+func newTransfer(ctx context.Context, opts Options) *transfer {
+	t := &transfer{}
+	t.tree = tree{ctx: ctx, opts: opts, w: t}
+	return t
+}
+
+func (t *transfer) mkdir(dst string) error {
+	return vfs.Mkdir(dst, 0700)
+}
+
+func (t *transfer) symlink(src, dst string, info fs.FileInfo, opts Options) error {
+	p := opts.progress()
+	p.FileStarted(src, 0)
+	target, err := vfs.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := vfs.Symlink(target, dst); err != nil {
+		return err
+	}
+	p.FileDone(src)
+	return nil
+}
+
+func (t *transfer) file(src, dst string, info fs.FileInfo, opts Options) error {
+	in, err := vfs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	p := opts.progress()
+	p.FileStarted(src, info.Size())
+	if err := writeFile(t.ctx, dst, info, in, opts); err != nil {
+		return err
+	}
+	p.FileDone(src)
+	opts.Batch.add(src, dst)
+	return nil
+}
+
+func (t *transfer) setMetadata(dst string, info fs.FileInfo) error {
+	return setMetadata(dst, info)
+}
+
+// writeFile writes r under a temporary name next to dst, on any backend,
+// and renames it into place once complete. With opts.Verify the written
+// file is read back and compared with what was read from r.
+func writeFile(ctx context.Context, dst string, info fs.FileInfo, r io.Reader, opts Options) (err error) {
+	out, tmp, err := createTempVFS(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			// Never leave a half-written file behind.
+			vfs.Remove(tmp)
+		}
+	}()
+
+	h := sha256.New()
+	if opts.Verify {
+		r = io.TeeReader(r, h)
+	}
+	// Large reads let remote backends keep several requests in flight.
+	buf := make([]byte, transferBufSize)
+	if _, err := io.CopyBuffer(progressWriter{w: out, p: opts.progress()}, ctxReader{ctx: ctx, r: r}, buf); err != nil {
+		return err
+	}
+	if f, ok := out.(*os.File); ok {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if opts.Verify {
+		if err := verifyPath(ctx, tmp, h.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	if err := setMetadata(tmp, info); err != nil {
+		return err
+	}
+	return vfs.Rename(tmp, dst)
+}
+
+// setMetadata applies the permissions and modification time of info, on
+// any backend. Ownership is left as it is: the destination may not share
+// the source's users, and extracted files belong to the user.
+func setMetadata(dst string, info fs.FileInfo) error {
+	if err := vfs.Chmod(dst, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	if info.ModTime().IsZero() {
+		return nil
+	}
+	return vfs.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,53 @@
+package fileops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"cfiler/internal/vfs"
+)
+
+// ErrChecksum is returned when a verified copy does not read back with the
+// same SHA-256 as its source.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// verifyFile reads f back from the start and compares its SHA-256 with
+// want. f must already be synced so the read comes from the device rather
+// than from what is still queued in memory.
+func verifyFile(ctx context.Context, f *os.File, want []byte) error {
+	dropCache(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return compareSum(ctx, f, want)
+}
+
+// verifyPath reads the file at path back, on any backend, and compares its
+// SHA-256 with want.
+func verifyPath(ctx context.Context, path string, want []byte) error {
+	rc, err := vfs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if f, ok := rc.(*os.File); ok {
+		dropCache(f)
+	}
+	return compareSum(ctx, rc, want)
+}
+
+func compareSum(ctx context.Context, r io.Reader, want []byte) error {
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, ctxReader{ctx: ctx, r: r}, make([]byte, transferBufSize)); err != nil {
+		return err
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: source %x, copy %x", ErrChecksum, want, got)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"cfiler/internal/vfs"
+)
+
+// tree is the walk shared by every copy of a source tree: each item is
+// resolved against what is already at its destination, existing
+// directories are merged into, a new directory is journaled as a whole,
+// and directory metadata is applied at the end, deepest first, once
+// everything below is written. A treeWriter does the writing.
+type tree struct {
+	ctx  context.Context
+	opts Options
+	w    treeWriter
+	dirs []dirTask // created directories, parents before children
+}
+
+type dirTask struct {
+	dst  string
+	info fs.FileInfo
+}
+
+// treeWriter creates the entries of a tree walk on one kind of
+// destination.
+type treeWriter interface {
+	mkdir(dst string) error
+	symlink(src, dst string, info fs.FileInfo, opts Options) error
+	// file copies a regular file, or queues it to be copied, and records
+	// it in opts.Batch once it is complete.
+	file(src, dst string, info fs.FileInfo, opts Options) error
+	setMetadata(dst string, info fs.FileInfo) error
+}
+
+// item copies src to dst, consulting opts.Resolve if dst exists.
+func (t *tree) item(src, dst string, info fs.FileInfo, opts Options) error {
+	dst, skip, merge, err := resolveDst(t.ctx, src, dst, info, opts)
+	if err != nil {
+		return err
+	}
+	if skip {
+		accountTree(src, opts.progress())
+		return nil
+	}
+	if merge {
+		// The directory existed before; its new entries are recorded
+		// one by one.
+		return t.dir(src, dst, opts)
+	}
+	if info.Mode().IsRegular() {
+		return t.w.file(src, dst, info, opts)
+	}
+	if err := t.node(src, dst, info, opts); err != nil {
+		return err
+	}
+	opts.Batch.add(src, dst)
+	return nil
+}
+
+// node copies src to a dst that does not exist yet. It is recorded by the
+// caller, so nothing below it is.
+func (t *tree) node(src, dst string, info fs.FileInfo, opts Options) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	opts.Batch = nil
+	switch {
+	case info.IsDir():
+		if err := t.w.mkdir(dst); err != nil {
+			return err
+		}
+		t.dirs = append(t.dirs, dirTask{dst: dst, info: info})
+		return t.dir(src, dst, opts)
+	case info.Mode()&fs.ModeSymlink != 0:
+		return t.w.symlink(src, dst, info, opts)
+	case info.Mode().IsRegular():
+		return t.w.file(src, dst, info, opts)
+	}
+	return fmt.Errorf("cannot copy special file: %s", src)
+}
+
+// dir walks the entries of src into dst.
+func (t *tree) dir(src, dst string, opts Options) error {
+	infos, err := vfs.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+		srcPath := vfs.Join(src, info.Name())
+		dstPath := vfs.Join(dst, info.Name())
+		var err error
+		if opts.Dereference && info.Mode()&fs.ModeSymlink != 0 {
+			info, err = vfs.Stat(srcPath)
+		}
+		if err == nil {
+			err = t.item(srcPath, dstPath, info, opts)
+		}
+		if err != nil {
+			if err := opts.fail(srcPath, dstPath, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// finishDirs applies the metadata of the created directories, children
+// before their parents, after writing the entries changed their mtime.
+// err is the result of the walk.
+func finishDirs(dirs []dirTask, set func(dst string, info fs.FileInfo) error, opts Options, err error) error {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if merr := set(d.dst, d.info); merr != nil && err == nil {
+			err = opts.fail(d.dst, d.dst, merr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+// Package fit sizes strings to terminal columns. Widths are counted in
+// runes.
+package fit
+
+import "strings"
+
+// Pad pads s with spaces or truncates it, marking the cut with "~", to
+// exactly n runes.
+func Pad(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) > n {
+		if n > 1 {
+			return string(r[:n-1]) + "~"
+		}
+		return string(r[:n])
+	}
+	return s + strings.Repeat(" ", n-len(r))
+}
+
+// Truncate shortens s to at most n runes, ending it with "…" if it was cut.
+func Truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,658 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cfiler/internal/fileops"
+	"cfiler/internal/vfs"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type Kind int
+
+const (
+	KindCopy Kind = iota
+	KindMove
+	KindDelete
+	KindTrash
+	KindRestore
+	KindSymlink
+	KindLink
+	KindAttrs
+	KindTouch
+	KindDuplicate
+	KindCompress
+	KindExtract
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindCopy:
+		return "Copy"
+	case KindMove:
+		return "Move"
+	case KindDelete:
+		return "Delete"
+	case KindTrash:
+		return "Trash"
+	case KindRestore:
+		return "Restore"
+	case KindSymlink:
+		return "Symlink"
+	case KindLink:
+		return "Hard link"
+	case KindAttrs:
+		return "Permissions"
+	case KindTouch:
+		return "Touch"
+	case KindDuplicate:
+		return "Duplicate"
+	case KindCompress:
+		return "Compress"
+	case KindExtract:
+		return "Extract"
+	}
+	return "Job"
+}
+
+// journalKinds maps the job kinds that can be undone to their journal entry.
+var journalKinds = map[Kind]fileops.OpKind{
+	KindCopy:      fileops.OpCopy,
+	KindMove:      fileops.OpMove,
+	KindTrash:     fileops.OpTrash,
+	KindSymlink:   fileops.OpSymlink,
+	KindDuplicate: fileops.OpCopy,
+	KindLink:      fileops.OpLink,
+	KindExtract:   fileops.OpExtract,
+}
+
+type FileState int
+
+const (
+	FileActive FileState = iota
+	FileDone
+)
+
+type FileStatus struct {
+	Path  string
+	State FileState
+}
+
+// Failure is an item that could not be processed. Dst is empty for kinds
+// without a destination.
+type Failure struct {
+	Src string
+	Dst string
+	Err error
+}
+
+// Status is a snapshot of a job's progress.
+type Status struct {
+	ID         int
+	Kind       Kind
+	Current    string
+	DoneFiles  int
+	TotalFiles int
+	DoneBytes  int64
+	TotalBytes int64
+	Started    time.Time
+	Finished   time.Time
+	Recent     []FileStatus
+	Items      []string // top-level paths the job was started with
+	Completed  []string // items from Items that finished successfully
+	Failures   []Failure
+	Done       bool
+	Cancelled  bool
+	Err        error
+}
+
+// Throughput returns the average transfer rate in bytes per second.
+func (s Status) Throughput() float64 {
+	end := s.Finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(s.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.DoneBytes) / elapsed
+}
+
+// ETA estimates the remaining time, or -1 if it cannot be estimated yet.
+func (s Status) ETA() time.Duration {
+	rate := s.Throughput()
+	if rate <= 0 || s.TotalBytes <= 0 {
+		return -1
+	}
+	remaining := s.TotalBytes - s.DoneBytes
+	if remaining < 0 {
+		remaining = 0
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second))
+}
+
+// Percent returns completion in the range 0-100.
+func (s Status) Percent() int {
+	if s.Done {
+		return 100
+	}
+	if s.TotalBytes > 0 {
+		return int(s.DoneBytes * 100 / s.TotalBytes)
+	}
+	if s.TotalFiles > 0 {
+		return s.DoneFiles * 100 / s.TotalFiles
+	}
+	return 0
+}
+
+// Summary is a one-line description used by the status bar.
+func (s Status) Summary() string {
+	text := fmt.Sprintf("%s %d%%", s.Kind, s.Percent())
+	if s.TotalBytes > 0 {
+		text += fmt.Sprintf(" %s/s", formatBytes(int64(s.Throughput())))
+		if eta := s.ETA(); eta >= 0 {
+			text += " ETA " + formatDuration(eta)
+		}
+	} else {
+		text += fmt.Sprintf(" %d/%d", s.DoneFiles, s.TotalFiles)
+	}
+	return text
+}
+
+type ProgressMsg struct {
+	Status
+}
+
+type DoneMsg struct {
+	Status
+}
+
+// ConflictMsg asks the UI how to handle an existing destination. The job
+// waits until Manager.Resolve is called or the job is cancelled.
+type ConflictMsg struct {
+	JobID    int
+	Conflict fileops.Conflict
+}
+
+type answer struct {
+	action fileops.ConflictAction
+	all    bool
+}
+
+const (
+	updateInterval = 100 * time.Millisecond
+	maxRecent      = 5
+	maxFinished    = 5
+)
+
+type runner struct {
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	status    Status
+	lastSent  time.Time
+	updates   chan Status
+	conflicts chan fileops.Conflict
+	answers   chan answer
+	applyAll  *fileops.ConflictAction
+}
+
+func (r *runner) FileStarted(path string, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Current = path
+	r.pushRecent(FileStatus{Path: path, State: FileActive})
+	r.send(false)
+}
+
+func (r *runner) BytesDone(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.DoneBytes += n
+	r.send(false)
+}
+
+func (r *runner) FileDone(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.DoneFiles++
+	for i := range r.status.Recent {
+		if r.status.Recent[i].Path == path {
+			r.status.Recent[i].State = FileDone
+		}
+	}
+	r.send(false)
+}
+
+// resolve forwards a conflict to the UI, unless an earlier answer was
+// marked "apply to all".
+func (r *runner) resolve(ctx context.Context, c fileops.Conflict) (fileops.ConflictAction, error) {
+	r.mu.Lock()
+	if r.applyAll != nil {
+		action := *r.applyAll
+		r.mu.Unlock()
+		return action, nil
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.conflicts <- c:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	select {
+	case a := <-r.answers:
+		if a.all {
+			r.mu.Lock()
+			r.applyAll = &a.action
+			r.mu.Unlock()
+		}
+		return a.action, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (r *runner) fail(src, dst string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Failures = append(r.status.Failures, Failure{Src: src, Dst: dst, Err: err})
+	r.send(false)
+}
+
+func (r *runner) pushRecent(fs FileStatus) {
+	recent := append(r.status.Recent, fs)
+	if len(recent) > maxRecent {
+		recent = recent[len(recent)-maxRecent:]
+	}
+	r.status.Recent = recent
+}
+
+// send publishes a snapshot, keeping only the latest one queued. Callers
+// must hold r.mu.
+func (r *runner) send(force bool) {
+	if !force && time.Since(r.lastSent) < updateInterval {
+		return
+	}
+	r.lastSent = time.Now()
+	snap := r.snapshot()
+	select {
+	case <-r.updates:
+	default:
+	}
+	r.updates <- snap
+}
+
+func (r *runner) id() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status.ID
+}
+
+func (r *runner) snapshot() Status {
+	s := r.status
+	s.Recent = append([]FileStatus(nil), r.status.Recent...)
+	s.Completed = append([]string(nil), r.status.Completed...)
+	s.Failures = append([]Failure(nil), r.status.Failures...)
+	return s
+}
+
+// Manager runs jobs in the background and tracks their progress.
+type Manager struct {
+	mu       sync.Mutex
+	nextID   int
+	running  map[int]*runner
+	subs     map[int]*runner // jobs whose DoneMsg has not been delivered yet
+	finished []Status
+	retries  map[int]retry // how to re-run the failures of finished jobs
+}
+
+type item struct {
+	src    string
+	dstDir string
+}
+
+type retry struct {
+	kind Kind
+	opts fileops.Options
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		running: make(map[int]*runner),
+		subs:    make(map[int]*runner),
+		retries: make(map[int]retry),
+	}
+}
+
+// Start launches a job over srcs and returns a command that delivers its
+// progress messages. dst is the destination directory for copy, move and
+// the link kinds and extract, and the archive to create for compress.
+// Progress, conflict resolution and journaling in opts are filled in by
+// the job; the remaining fields are passed through to fileops.
+func (m *Manager) Start(kind Kind, srcs []string, dst string, opts fileops.Options) tea.Cmd {
+	items := make([]item, len(srcs))
+	for i, src := range srcs {
+		items[i] = item{src: src, dstDir: dst}
+	}
+	return m.start(kind, items, opts)
+}
+
+// Retry starts a new job for the failed items of finished job id.
+func (m *Manager) Retry(id int) tea.Cmd {
+	m.mu.Lock()
+	rt, ok := m.retries[id]
+	var failures []Failure
+	for _, s := range m.finished {
+		if s.ID == id {
+			failures = s.Failures
+		}
+	}
+	m.mu.Unlock()
+	if !ok || len(failures) == 0 {
+		return nil
+	}
+
+	items := make([]item, len(failures))
+	for i, f := range failures {
+		items[i] = item{src: f.Src}
+		if f.Dst != "" {
+			items[i].dstDir = vfs.Dir(f.Dst)
+		}
+	}
+	return m.start(rt.kind, items, rt.opts)
+}
+
+func (m *Manager) start(kind Kind, items []item, opts fileops.Options) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	srcs := make([]string, len(items))
+	for i, it := range items {
+		srcs[i] = it.src
+	}
+
+	m.mu.Lock()
+	m.nextID++
+	r := &runner{
+		cancel: cancel,
+		status: Status{
+			ID:      m.nextID,
+			Kind:    kind,
+			Started: time.Now(),
+			Items:   append([]string(nil), srcs...),
+		},
+		updates:   make(chan Status, 1),
+		conflicts: make(chan fileops.Conflict),
+		answers:   make(chan answer, 1),
+	}
+	m.running[r.status.ID] = r
+	m.subs[r.status.ID] = r
+	m.mu.Unlock()
+
+	go m.run(ctx, r, items, opts)
+	return m.wait(r)
+}
+
+// Resolve answers the pending conflict of job id. With all set, the same
+// action is used for every later conflict of that job.
+func (m *Manager) Resolve(id int, action fileops.ConflictAction, all bool) {
+	m.mu.Lock()
+	r, ok := m.running[id]
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case r.answers <- answer{action: action, all: all}:
+	default:
+	}
+}
+
+// IsRunning reports whether job id has not finished yet.
+func (m *Manager) IsRunning(id int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.running[id]
+	return ok
+}
+
+// Cancel aborts job id if it is still running.
+func (m *Manager) Cancel(id int) {
+	m.mu.Lock()
+	r, ok := m.running[id]
+	m.mu.Unlock()
+	if ok {
+		r.cancel()
+	}
+}
+
+// Wait returns a command that delivers the next message for job id.
+func (m *Manager) Wait(id int) tea.Cmd {
+	m.mu.Lock()
+	r, ok := m.subs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return m.wait(r)
+}
+
+func (m *Manager) wait(r *runner) tea.Cmd {
+	return func() tea.Msg {
+		var s Status
+		var ok bool
+		select {
+		case s, ok = <-r.updates:
+		case c := <-r.conflicts:
+			return ConflictMsg{JobID: r.id(), Conflict: c}
+		}
+		if !ok {
+			return nil
+		}
+		if s.Done {
+			m.mu.Lock()
+			delete(m.subs, s.ID)
+			m.mu.Unlock()
+			return DoneMsg{Status: s}
+		}
+		return ProgressMsg{Status: s}
+	}
+}
+
+// Running returns snapshots of the jobs still in progress, oldest first.
+func (m *Manager) Running() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Status
+	for id := 1; id <= m.nextID; id++ {
+		if r, ok := m.running[id]; ok {
+			r.mu.Lock()
+			out = append(out, r.snapshot())
+			r.mu.Unlock()
+		}
+	}
+	return out
+}
+
+// All returns running jobs followed by recently finished ones.
+func (m *Manager) All() []Status {
+	out := m.Running()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.finished) - 1; i >= 0; i-- {
+		out = append(out, m.finished[i])
+	}
+	return out
+}
+
+func (m *Manager) run(ctx context.Context, r *runner, items []item, opts fileops.Options) {
+	defer r.cancel()
+	retryOpts := opts
+
+	measure := fileops.Measure
+	if r.status.Kind == KindExtract {
+		// Progress follows the contents, not the archive files.
+		measure = fileops.MeasureArchives
+	}
+	files, bytes := measure(r.status.Items)
+	r.mu.Lock()
+	r.status.TotalFiles = files
+	r.status.TotalBytes = bytes
+	r.send(true)
+	r.mu.Unlock()
+
+	opts.Progress = r
+	opts.Resolve = r.resolve
+	opts.OnError = r.fail
+	if op, ok := journalKinds[r.status.Kind]; ok {
+		opts.Batch = fileops.NewBatch(op)
+	}
+	var err error
+	if r.status.Kind == KindCompress {
+		err = r.compress(ctx, items, opts)
+	} else {
+		err = r.runItems(ctx, items, opts)
+	}
+
+	// Whatever completed, even in a failed or cancelled job, can be undone.
+	if opts.Batch != nil {
+		_ = opts.Batch.Commit()
+	}
+
+	r.mu.Lock()
+	r.status.Done = true
+	if errors.Is(err, context.Canceled) {
+		r.status.Cancelled = true
+		err = nil
+	}
+	r.status.Err = err
+	r.status.Current = ""
+	r.status.Finished = time.Now()
+	final := r.snapshot()
+	r.mu.Unlock()
+
+	m.mu.Lock()
+	delete(m.running, final.ID)
+	m.finished = append(m.finished, final)
+	if len(final.Failures) > 0 {
+		m.retries[final.ID] = retry{kind: final.Kind, opts: retryOpts}
+	}
+	if len(m.finished) > maxFinished {
+		for _, s := range m.finished[:len(m.finished)-maxFinished] {
+			delete(m.retries, s.ID)
+		}
+		m.finished = m.finished[len(m.finished)-maxFinished:]
+	}
+	m.mu.Unlock()
+
+	r.mu.Lock()
+	r.send(true)
+	close(r.updates)
+	r.mu.Unlock()
+}
+
+// runItems performs the job's operation on each item in turn. Failed
+// items are collected for the report and the rest carry on; only a
+// cancellation stops the job early.
+func (r *runner) runItems(ctx context.Context, items []item, opts fileops.Options) error {
+	for _, it := range items {
+		r.mu.Lock()
+		failuresBefore := len(r.status.Failures)
+		r.mu.Unlock()
+
+		var err error
+		switch r.status.Kind {
+		case KindCopy:
+			err = fileops.Copy(ctx, it.src, it.dstDir, opts)
+		case KindMove:
+			err = fileops.Move(ctx, it.src, it.dstDir, opts)
+		case KindDelete:
+			err = fileops.Delete(ctx, it.src, opts)
+		case KindTrash:
+			err = fileops.Trash(ctx, it.src, opts)
+		case KindRestore:
+			err = fileops.Restore(ctx, it.src, opts)
+		case KindSymlink:
+			err = fileops.Symlink(ctx, it.src, it.dstDir, opts)
+		case KindLink:
+			err = fileops.Link(ctx, it.src, it.dstDir, opts)
+		case KindDuplicate:
+			// A retried entry from inside a duplicated directory goes
+			// back into the copy it failed to reach.
+			if it.dstDir != "" {
+				err = fileops.Copy(ctx, it.src, it.dstDir, opts)
+			} else {
+				err = fileops.Duplicate(ctx, it.src, opts)
+			}
+		case KindAttrs, KindTouch:
+			err = fileops.SetAttrs(ctx, it.src, opts)
+		case KindExtract:
+			err = fileops.Extract(ctx, it.src, it.dstDir, opts)
+		}
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Failed items are collected for the report and the batch goes on.
+		if err != nil {
+			var dst string
+			if it.dstDir != "" {
+				dst = vfs.Join(it.dstDir, vfs.Base(it.src))
+			}
+			r.fail(it.src, dst, err)
+			continue
+		}
+
+		r.mu.Lock()
+		if len(r.status.Failures) == failuresBefore {
+			r.status.Completed = append(r.status.Completed, it.src)
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// compress writes all items into one archive. The archive is complete or
+// not created at all, so the first error fails the whole job instead of
+// being collected.
+func (r *runner) compress(ctx context.Context, items []item, opts fileops.Options) error {
+	srcs := make([]string, len(items))
+	for i, it := range items {
+		srcs[i] = it.src
+	}
+	opts.OnError = nil
+	if err := fileops.Compress(ctx, srcs, items[0].dstDir, opts); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.status.Completed = append(r.status.Completed, srcs...)
+	r.mu.Unlock()
+	return nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fK", float64(n)/1024)
+	case n < 1024*1024*1024:
+		return fmt.Sprintf("%.1fM", float64(n)/(1024*1024))
+	default:
+		return fmt.Sprintf("%.1fG", float64(n)/(1024*1024*1024))
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,180 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	"cfiler/internal/fit"
+	"cfiler/internal/vfs"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type CloseMsg struct{}
+
+// Model is the progress overlay listing running and finished jobs.
+type Model struct {
+	manager *Manager
+	cursor  int
+	width   int
+	height  int
+}
+
+func NewModel(manager *Manager, width, height int) Model {
+	return Model{
+		manager: manager,
+		width:   width,
+		height:  height,
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.manager.All())-1 {
+				m.cursor++
+			}
+		case "esc":
+			// Esc aborts the selected job; once nothing is running it closes.
+			statuses := m.manager.All()
+			if m.cursor < len(statuses) && !statuses[m.cursor].Done {
+				m.manager.Cancel(statuses[m.cursor].ID)
+				return m, nil
+			}
+			return m, func() tea.Msg { return CloseMsg{} }
+		case "r":
+			statuses := m.manager.All()
+			if m.cursor < len(statuses) {
+				return m, m.manager.Retry(statuses[m.cursor].ID)
+			}
+		case "J", "q":
+			return m, func() tea.Msg { return CloseMsg{} }
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	dialogW := m.width * 2 / 3
+	if dialogW < 60 {
+		dialogW = 60
+	}
+	if dialogW > m.width-4 {
+		dialogW = m.width - 4
+	}
+	innerW := dialogW - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+	nameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#7aa2f7")).
+		Bold(true)
+	errStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#db4b4b"))
+	doneStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#9ece6a"))
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Jobs"))
+	b.WriteString("\n\n")
+
+	statuses := m.manager.All()
+	if len(statuses) == 0 {
+		b.WriteString(dimStyle.Render("No jobs."))
+	}
+	for i, s := range statuses {
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "▸ "
+		}
+
+		var state string
+		switch {
+		case s.Done && s.Err != nil:
+			state = errStyle.Render("failed: " + s.Err.Error())
+		case s.Cancelled:
+			state = errStyle.Render(fmt.Sprintf("cancelled (%d/%d items completed)", len(s.Completed), len(s.Items)))
+		case s.Done && len(s.Failures) > 0:
+			state = errStyle.Render(fmt.Sprintf("done, %d failed", len(s.Failures)))
+		case s.Done:
+			state = doneStyle.Render("done")
+		default:
+			state = s.Summary()
+		}
+		b.WriteString(fmt.Sprintf("%s%s  %s\n", cursor, nameStyle.Render(fmt.Sprintf("#%d %s", s.ID, s.Kind)), state))
+
+		b.WriteString("    " + progressBar(s.Percent(), innerW-4) + "\n")
+		detail := fmt.Sprintf("%d/%d files", s.DoneFiles, s.TotalFiles)
+		if s.TotalBytes > 0 {
+			detail += fmt.Sprintf("  %s/%s", formatBytes(s.DoneBytes), formatBytes(s.TotalBytes))
+		}
+		b.WriteString("    " + dimStyle.Render(detail) + "\n")
+
+		if i == m.cursor && len(s.Failures) > 0 {
+			for _, f := range s.Failures {
+				line := fit.Truncate(fmt.Sprintf("✗ %s: %v", f.Src, f.Err), innerW-4)
+				b.WriteString("    " + errStyle.Render(line) + "\n")
+			}
+		} else if i == m.cursor && s.Done && len(s.Completed) < len(s.Items) {
+			for _, item := range s.Items {
+				mark := "✗"
+				if contains(s.Completed, item) {
+					mark = "✓"
+				}
+				line := fit.Truncate(fmt.Sprintf("%s %s", mark, item), innerW-4)
+				b.WriteString("    " + dimStyle.Render(line) + "\n")
+			}
+		} else if i == m.cursor {
+			for _, f := range s.Recent {
+				mark := "…"
+				if f.State == FileDone {
+					mark = "✓"
+				}
+				line := fit.Truncate(fmt.Sprintf("%s %s", mark, vfs.Base(f.Path)), innerW-4)
+				b.WriteString("    " + dimStyle.Render(line) + "\n")
+			}
+		}
+		if i < len(statuses)-1 {
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("↑/↓: select  Esc: cancel job / close  r: retry failed  q: close"))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(b.String())
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func progressBar(percent, width int) string {
+	if width < 10 {
+		width = 10
+	}
+	filled := width * percent / 100
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("#7aa2f7")).Render(strings.Repeat("█", filled)) +
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#292e42")).Render(strings.Repeat("░", width-filled))
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// FileEntry is one row of a pane. For a symlink, Mode is the link's own
+// mode while IsDir, Size and ModTime describe its target, so links to
+// directories can be entered like directories.
 type FileEntry struct {
 	Name    string
 	Size    int64
@@ -12,4 +15,8 @@ type FileEntry struct {
 	IsDir   bool
 	Mode    fs.FileMode
 	IsLink  bool
+
+	LinkTarget string      // the link's contents, as stored
+	TargetMode fs.FileMode // mode of what the link resolves to
+	Broken     bool        // the link's target does not exist
 }
@@ -8,6 +8,8 @@ import (
 	"sort"
 	"strings"
 
+	"cfiler/internal/vfs"
+
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -59,7 +61,7 @@ func (m Model) SelectedEntry() (FileEntry, bool) {
 
 func (m Model) SelectedPath() string {
 	if entry, ok := m.SelectedEntry(); ok {
-		return filepath.Join(m.dir, entry.Name)
+		return vfs.Join(m.dir, entry.Name)
 	}
 	return ""
 }
@@ -250,18 +252,25 @@ func (m Model) IsMarked(name string) bool {
 	return m.marked[name]
 }
 
+// MarkedNames returns the names of the marked entries in the order the
+// pane lists them.
 func (m Model) MarkedNames() []string {
 	var names []string
-	for name := range m.marked {
-		names = append(names, name)
+	for _, e := range m.entries {
+		if m.marked[e.Name] {
+			names = append(names, e.Name)
+		}
 	}
 	return names
 }
 
+// MarkedPaths returns the marked entries in the order the pane lists them.
 func (m Model) MarkedPaths() []string {
 	var paths []string
-	for name := range m.marked {
-		paths = append(paths, filepath.Join(m.dir, name))
+	for _, e := range m.entries {
+		if m.marked[e.Name] {
+			paths = append(paths, vfs.Join(m.dir, e.Name))
+		}
 	}
 	return paths
 }
@@ -338,6 +347,26 @@ func listDrives() ([]FileEntry, error) {
 	return entries, nil
 }
 
+// resolveLink fills in what the symlink at path points to. Backends that
+// do not follow links leave the link as it is.
+func resolveLink(fsys vfs.FS, entry *FileEntry, path string) {
+	entry.LinkTarget, _ = fsys.Readlink(path)
+	target, err := fsys.Stat(path)
+	if err != nil {
+		entry.Broken = true
+		return
+	}
+	if target.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+	entry.TargetMode = target.Mode()
+	entry.IsDir = target.IsDir()
+	entry.Size = target.Size()
+	entry.ModTime = target.ModTime()
+}
+
+// LoadDir lists dir, a location on any backend: the local disk, the inside
+// of an archive, which is shown read-only, or a remote host.
 func LoadDir(id int, dir string) tea.Cmd {
 	return func() tea.Msg {
 		if dir == "" && runtime.GOOS == "windows" {
@@ -348,21 +377,28 @@ func LoadDir(id int, dir string) tea.Cmd {
 			return DirLoadedMsg{Entries: entries, Path: "", PaneID: id}
 		}
 
-		dirEntries, err := os.ReadDir(dir)
+		absDir := dir
+		if vfs.Scheme(dir) == "" {
+			absDir, _ = filepath.Abs(dir)
+		}
+		fsys, err := vfs.Resolve(absDir)
+		if err != nil {
+			return DirLoadErrorMsg{Err: err, PaneID: id}
+		}
+		infos, err := fsys.ReadDir(absDir)
 		if err != nil {
 			return DirLoadErrorMsg{Err: err, PaneID: id}
 		}
 
 		var entries []FileEntry
 		// Add parent directory entry
-		absDir, _ := filepath.Abs(dir)
-		parent := filepath.Dir(absDir)
+		parent := vfs.Dir(absDir)
 		if parent != absDir {
 			entries = append(entries, FileEntry{
 				Name:  "..",
 				IsDir: true,
 			})
-		} else if runtime.GOOS == "windows" {
+		} else if runtime.GOOS == "windows" && vfs.Scheme(absDir) == "" {
 			// At drive root, ".." goes to drive list
 			entries = append(entries, FileEntry{
 				Name:  "..",
@@ -371,32 +407,27 @@ func LoadDir(id int, dir string) tea.Cmd {
 		}
 
 		var dirs, files []FileEntry
-		for _, de := range dirEntries {
-			info, err := de.Info()
-			if err != nil {
-				continue
-			}
+		for _, info := range infos {
 			entry := FileEntry{
-				Name:    de.Name(),
+				Name:    info.Name(),
 				Size:    info.Size(),
 				ModTime: info.ModTime(),
-				IsDir:   de.IsDir(),
+				IsDir:   info.IsDir(),
 				Mode:    info.Mode(),
-				IsLink:  de.Type()&os.ModeSymlink != 0,
+				IsLink:  info.Mode()&os.ModeSymlink != 0,
+			}
+			if entry.IsLink {
+				resolveLink(fsys, &entry, vfs.Join(absDir, info.Name()))
 			}
-			if de.IsDir() {
+			if entry.IsDir {
 				dirs = append(dirs, entry)
 			} else {
 				files = append(files, entry)
 			}
 		}
 
-		sort.Slice(dirs, func(i, j int) bool {
-			return strings.ToLower(dirs[i].Name) < strings.ToLower(dirs[j].Name)
-		})
-		sort.Slice(files, func(i, j int) bool {
-			return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
-		})
+		sortByName(dirs)
+		sortByName(files)
 
 		entries = append(entries, dirs...)
 		entries = append(entries, files...)
@@ -404,3 +435,9 @@ func LoadDir(id int, dir string) tea.Cmd {
 		return DirLoadedMsg{Entries: entries, Path: absDir, PaneID: id}
 	}
 }
+
+func sortByName(entries []FileEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+}
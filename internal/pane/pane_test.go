@@ -0,0 +1,65 @@
+package pane
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cfiler/internal/rename"
+)
+
+func TestMarkedPathsInPaneOrder(t *testing.T) {
+	dir := t.TempDir()
+	m := New(0, dir)
+	var entries []FileEntry
+	for _, name := range []string{"..", "a", "b", "c", "d", "e", "f", "g", "h"} {
+		entries = append(entries, FileEntry{Name: name})
+	}
+	m.SetEntries(entries)
+	for _, name := range []string{"g", "c", "e", "a", "h"} {
+		m.SetMark(name, true)
+	}
+
+	var names []string
+	for _, p := range m.MarkedPaths() {
+		if filepath.Dir(p) != dir {
+			t.Errorf("%s is not in %s", p, dir)
+		}
+		names = append(names, filepath.Base(p))
+	}
+	want := []string{"a", "c", "e", "g", "h"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("marked %v, want %v", names, want)
+	}
+
+	// The batch rename counter follows the pane.
+	results, err := rename.Preview(nil, names, rename.Rule{Template: "{n}-{name}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var renamed []string
+	for _, r := range results {
+		renamed = append(renamed, r.New)
+	}
+	want = []string{"1-a", "2-c", "3-e", "4-g", "5-h"}
+	if !reflect.DeepEqual(renamed, want) {
+		t.Errorf("renamed to %v, want %v", renamed, want)
+	}
+}
+
+func TestMarkedNamesInPaneOrder(t *testing.T) {
+	m := New(0, t.TempDir())
+	var entries []FileEntry
+	for _, name := range []string{"..", "a", "b", "c", "d", "e", "f", "g", "h"} {
+		entries = append(entries, FileEntry{Name: name})
+	}
+	m.SetEntries(entries)
+	for _, name := range []string{"h", "b", "f", "d"} {
+		m.SetMark(name, true)
+	}
+	// These are the lines of the file edited in $EDITOR.
+	want := []string{"b", "d", "f", "h"}
+	if names := m.MarkedNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("marked %v, want %v", names, want)
+	}
+}
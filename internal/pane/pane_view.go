@@ -60,7 +60,7 @@ func (m Model) View(active bool) string {
 		// Build name part
 		name := entry.Name
 		if entry.IsLink {
-			name += " @"
+			name += " -> " + entry.LinkTarget
 		}
 		namePadded := padOrTruncate(name, nameWidth)
 
@@ -110,10 +110,12 @@ func (m Model) View(active bool) string {
 		} else {
 			// Normal: name colored, details gray
 			var nameSt lipgloss.Style
-			if entry.IsDir {
-				nameSt = lipgloss.NewStyle().Foreground(lipgloss.Color("#7aa2f7")).Bold(true)
+			if entry.Broken {
+				nameSt = lipgloss.NewStyle().Foreground(lipgloss.Color("#db4b4b"))
 			} else if entry.IsLink {
-				nameSt = lipgloss.NewStyle().Foreground(lipgloss.Color("#bb9af7"))
+				nameSt = lipgloss.NewStyle().Foreground(lipgloss.Color("#bb9af7")).Bold(entry.IsDir)
+			} else if entry.IsDir {
+				nameSt = lipgloss.NewStyle().Foreground(lipgloss.Color("#7aa2f7")).Bold(true)
 			} else {
 				nameSt = lipgloss.NewStyle().Foreground(lipgloss.Color("#c0caf5"))
 			}
@@ -0,0 +1,370 @@
+package perms
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"cfiler/internal/fileops"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ApplyMsg asks the app to apply Attrs to Paths.
+type ApplyMsg struct {
+	Paths []string
+	Attrs fileops.Attrs
+}
+
+type CloseMsg struct{}
+
+const (
+	focusGrid = iota
+	focusFileOctal
+	focusDirOctal
+	focusRecursive
+	focusOwner
+	focusGroup
+	focusCount
+)
+
+const (
+	sideFiles = iota
+	sideDirs
+)
+
+// The grid has a row each for owner, group and other with the r, w and x
+// bits, and a row for setuid, setgid and sticky; files on the left, dirs
+// on the right.
+const (
+	gridRows = 4
+	gridCols = 6
+)
+
+const modeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Model is the permissions and ownership editor.
+type Model struct {
+	paths      []string
+	modes      [2]fs.FileMode
+	modeEdited [2]bool
+	recursive  bool
+	octal      [2]textinput.Model
+	owner      textinput.Model
+	group      textinput.Model
+	initOwner  string
+	initGroup  string
+	focus      int
+	row, col   int
+	err        error
+	width      int
+	height     int
+}
+
+func NewModel(paths []string, width, height int) Model {
+	m := Model{paths: paths, width: width, height: height}
+	m.modes = initialModes(paths)
+
+	for i := range m.octal {
+		ti := textinput.New()
+		ti.Prompt = ""
+		ti.CharLimit = 4
+		ti.Width = 5
+		ti.SetValue(toOctal(m.modes[i]))
+		m.octal[i] = ti
+	}
+	m.owner = textinput.New()
+	m.owner.Placeholder = "user"
+	m.owner.CharLimit = 64
+	m.owner.Width = 16
+	m.group = textinput.New()
+	m.group.Placeholder = "group"
+	m.group.CharLimit = 64
+	m.group.Width = 16
+	if info, err := os.Lstat(paths[0]); err == nil {
+		m.initOwner, m.initGroup = fileops.OwnerNames(info)
+		m.owner.SetValue(m.initOwner)
+		m.group.SetValue(m.initGroup)
+	}
+	return m
+}
+
+// initialModes takes the file and directory modes from the first file and
+// the first directory in paths, deriving one from the other if the
+// selection has only one kind.
+func initialModes(paths []string) [2]fs.FileMode {
+	var modes [2]fs.FileMode
+	var found [2]bool
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		if err != nil || info.Mode()&fs.ModeSymlink != 0 {
+			continue
+		}
+		side := sideFiles
+		if info.IsDir() {
+			side = sideDirs
+		}
+		if !found[side] {
+			modes[side] = info.Mode() & modeBits
+			found[side] = true
+		}
+		if found[sideFiles] && found[sideDirs] {
+			break
+		}
+	}
+	switch {
+	case !found[sideFiles] && !found[sideDirs]:
+		modes = [2]fs.FileMode{0644, 0755}
+	case !found[sideFiles]:
+		modes[sideFiles] = modes[sideDirs] &^ (0111 | fs.ModeSticky)
+	case !found[sideDirs]:
+		perm := modes[sideFiles] & fs.ModePerm
+		modes[sideDirs] = perm | perm&0444>>2
+	}
+	return modes
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch keyMsg.String() {
+	case "esc":
+		return m, func() tea.Msg { return CloseMsg{} }
+	case "enter":
+		attrs, err := m.attrs()
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		paths := m.paths
+		return m, func() tea.Msg { return ApplyMsg{Paths: paths, Attrs: attrs} }
+	case "tab":
+		return m, m.setFocus((m.focus + 1) % focusCount)
+	case "shift+tab":
+		return m, m.setFocus((m.focus + focusCount - 1) % focusCount)
+	}
+
+	var cmd tea.Cmd
+	switch m.focus {
+	case focusGrid:
+		m.updateGrid(keyMsg)
+	case focusRecursive:
+		if keyMsg.String() == " " || keyMsg.String() == "x" {
+			m.recursive = !m.recursive
+		}
+	case focusFileOctal, focusDirOctal:
+		side := m.focus - focusFileOctal
+		m.octal[side], cmd = m.octal[side].Update(msg)
+		if n, err := strconv.ParseUint(m.octal[side].Value(), 8, 32); err == nil && n <= 07777 {
+			m.modes[side] = fromOctal(n)
+			m.modeEdited[side] = true
+		}
+	case focusOwner:
+		m.owner, cmd = m.owner.Update(msg)
+	case focusGroup:
+		m.group, cmd = m.group.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m *Model) updateGrid(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "up", "k":
+		if m.row > 0 {
+			m.row--
+		}
+	case "down", "j":
+		if m.row < gridRows-1 {
+			m.row++
+		}
+	case "left", "h":
+		if m.col > 0 {
+			m.col--
+		}
+	case "right", "l":
+		if m.col < gridCols-1 {
+			m.col++
+		}
+	case " ", "x":
+		side := m.col / 3
+		m.modes[side] ^= gridBit(m.row, m.col%3)
+		m.octal[side].SetValue(toOctal(m.modes[side]))
+		m.modeEdited[side] = true
+	}
+}
+
+func (m *Model) setFocus(focus int) tea.Cmd {
+	m.octal[sideFiles].Blur()
+	m.octal[sideDirs].Blur()
+	m.owner.Blur()
+	m.group.Blur()
+	m.focus = focus
+	switch focus {
+	case focusFileOctal:
+		return m.octal[sideFiles].Focus()
+	case focusDirOctal:
+		return m.octal[sideDirs].Focus()
+	case focusOwner:
+		return m.owner.Focus()
+	case focusGroup:
+		return m.group.Focus()
+	}
+	return nil
+}
+
+// attrs builds the change to make. Each mode and the ownership are only
+// included when they were edited, so selecting entries with different
+// modes and changing only the owner, or only the file mode, leaves the
+// other modes alone.
+func (m Model) attrs() (fileops.Attrs, error) {
+	a := fileops.Attrs{
+		SetFileMode: m.modeEdited[sideFiles],
+		SetDirMode:  m.modeEdited[sideDirs],
+		FileMode:    m.modes[sideFiles],
+		DirMode:     m.modes[sideDirs],
+		UID:         -1,
+		GID:         -1,
+		Recursive:   m.recursive,
+	}
+	if name := strings.TrimSpace(m.owner.Value()); name != "" && name != m.initOwner {
+		uid, err := fileops.LookupUser(name)
+		if err != nil {
+			return a, err
+		}
+		a.UID = uid
+	}
+	if name := strings.TrimSpace(m.group.Value()); name != "" && name != m.initGroup {
+		gid, err := fileops.LookupGroup(name)
+		if err != nil {
+			return a, err
+		}
+		a.GID = gid
+	}
+	return a, nil
+}
+
+// gridBit returns the mode bit at row and column (0-2) of one side.
+func gridBit(row, col int) fs.FileMode {
+	if row == gridRows-1 {
+		return [3]fs.FileMode{fs.ModeSetuid, fs.ModeSetgid, fs.ModeSticky}[col]
+	}
+	return 1 << (8 - (row*3 + col))
+}
+
+// toOctal formats a mode as chmod does, with the special bits in front.
+func toOctal(mode fs.FileMode) string {
+	n := uint32(mode & fs.ModePerm)
+	if mode&fs.ModeSetuid != 0 {
+		n |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		n |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		n |= 01000
+	}
+	return fmt.Sprintf("%04o", n)
+}
+
+func fromOctal(n uint64) fs.FileMode {
+	mode := fs.FileMode(n) & fs.ModePerm
+	if n&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if n&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if n&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+func (m Model) View() string {
+	dialogW := 64
+	if dialogW > m.width-4 {
+		dialogW = m.width - 4
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#7aa2f7")).
+		Width(10)
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+	errStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#db4b4b"))
+	cursorStyle := lipgloss.NewStyle().
+		Background(lipgloss.Color("#283457")).
+		Foreground(lipgloss.Color("#e0af68"))
+
+	var b strings.Builder
+	title := fmt.Sprintf("Permissions: %d items", len(m.paths))
+	if len(m.paths) == 1 {
+		title = "Permissions: " + m.paths[0]
+	}
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	b.WriteString(labelStyle.Render("") + dimStyle.Render("Files      Dirs") + "\n")
+	b.WriteString(labelStyle.Render("") + dimStyle.Render(" r  w  x    r  w  x") + "\n")
+	rowLabels := [gridRows]string{"Owner", "Group", "Other", "Special"}
+	for row := 0; row < gridRows; row++ {
+		b.WriteString(labelStyle.Render(rowLabels[row]))
+		for col := 0; col < gridCols; col++ {
+			if col == 3 {
+				b.WriteString("  ")
+			}
+			cell := "[ ]"
+			if m.modes[col/3]&gridBit(row, col%3) != 0 {
+				cell = "[x]"
+			}
+			if m.focus == focusGrid && row == m.row && col == m.col {
+				cell = cursorStyle.Render(cell)
+			}
+			b.WriteString(cell)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(labelStyle.Render("Octal"))
+	b.WriteString(m.octal[sideFiles].View() + "      " + m.octal[sideDirs].View())
+	b.WriteString("\n")
+
+	check := "[ ]"
+	if m.recursive {
+		check = "[x]"
+	}
+	if m.focus == focusRecursive {
+		check = cursorStyle.Render(check)
+	}
+	b.WriteString(labelStyle.Render("Recursive") + check)
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("Special: setuid, setgid, sticky. Files get the left\nmode and directories the right one."))
+	b.WriteString("\n\n")
+
+	b.WriteString(labelStyle.Render("Owner") + m.owner.View() + "\n")
+	b.WriteString(labelStyle.Render("Group") + m.group.View() + "\n\n")
+
+	if m.err != nil {
+		b.WriteString(errStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+	} else {
+		b.WriteString(dimStyle.Render("Space: toggle  Tab: next field  Enter: apply  Esc: cancel"))
+	}
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(b.String())
+}
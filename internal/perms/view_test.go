@@ -0,0 +1,56 @@
+package perms
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cfiler/internal/fileops"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Editing only the file mode of a mixed selection leaves the directories'
+// modes as they were.
+func TestEditFileModeOnly(t *testing.T) {
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "file")
+	dir := filepath.Join(tmp, "dir")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0751); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewModel([]string{file, dir}, 100, 40)
+	// The grid starts on the files' owner read bit; go to group read.
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	a, err := m.attrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.SetFileMode || a.SetDirMode {
+		t.Fatalf("SetFileMode %v, SetDirMode %v; want only the file mode set", a.SetFileMode, a.SetDirMode)
+	}
+
+	for _, p := range []string{file, dir} {
+		if err := fileops.SetAttrs(context.Background(), p, fileops.Options{Attrs: &a}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, want := range map[string]os.FileMode{file: 0640, dir: 0751} {
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %v, want %v", filepath.Base(p), got, want)
+		}
+	}
+}
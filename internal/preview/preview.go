@@ -2,10 +2,15 @@ package preview
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
+	"cfiler/internal/archive"
+	"cfiler/internal/vfs"
+
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -107,61 +112,68 @@ type LoadMsg struct {
 	Path     string
 }
 
+// LoadFile reads the start of the file at path, on any backend, or lists
+// it if it is a directory or an archive.
 func LoadFile(path string) func() (string, bool, error) {
 	return func() (string, bool, error) {
-		info, err := os.Stat(path)
+		fsys, err := vfs.Resolve(path)
+		if err != nil {
+			return "", false, err
+		}
+		info, err := fsys.Stat(path)
 		if err != nil {
 			return "", false, err
 		}
-		if info.IsDir() {
-			entries, err := os.ReadDir(path)
+		// An archive file is listed like a directory.
+		if info.IsDir() || archive.Detect(path) != archive.FormatNone && fsys == vfs.Local {
+			infos, err := fsys.ReadDir(path)
 			if err != nil {
 				return "", false, err
 			}
+			sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
 			var b strings.Builder
 			b.WriteString(fmt.Sprintf("Directory: %s\n", path))
-			b.WriteString(fmt.Sprintf("%d items\n\n", len(entries)))
-			for _, e := range entries {
-				if e.IsDir() {
-					b.WriteString(fmt.Sprintf("  [DIR] %s\n", e.Name()))
+			b.WriteString(fmt.Sprintf("%d items\n\n", len(infos)))
+			for _, info := range infos {
+				if info.IsDir() {
+					b.WriteString(fmt.Sprintf("  [DIR] %s\n", info.Name()))
 				} else {
-					info, _ := e.Info()
-					if info != nil {
-						b.WriteString(fmt.Sprintf("  %s (%d bytes)\n", e.Name(), info.Size()))
-					}
+					b.WriteString(fmt.Sprintf("  %s (%d bytes)\n", info.Name(), info.Size()))
 				}
 			}
 			return b.String(), false, nil
 		}
-
-		readSize := maxPreviewBytes
-		if info.Size() < int64(readSize) {
-			readSize = int(info.Size())
+		// Backends that do not follow links show the target instead.
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := fsys.Readlink(path)
+			if err != nil {
+				return "", false, err
+			}
+			return "-> " + target, false, nil
 		}
 
-		f, err := os.Open(path)
+		f, err := fsys.Open(path)
 		if err != nil {
 			return "", false, err
 		}
 		defer f.Close()
 
-		buf := make([]byte, readSize)
-		n, err := f.Read(buf)
-		if err != nil && n == 0 {
+		buf := make([]byte, maxPreviewBytes)
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 			return "", false, err
 		}
-		buf = buf[:n]
-
-		if isBinaryData(buf) {
-			return "", true, nil
-		}
-
-		if !utf8.Valid(buf) {
-			return "", true, nil
-		}
+		content, isBinary := decode(buf[:n])
+		return content, isBinary, nil
+	}
+}
 
-		return string(buf), false, nil
+// decode returns buf as text, or reports it as binary.
+func decode(buf []byte) (string, bool) {
+	if isBinaryData(buf) || !utf8.Valid(buf) {
+		return "", true
 	}
+	return string(buf), false
 }
 
 func isBinaryData(data []byte) bool {
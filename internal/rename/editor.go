@@ -0,0 +1,104 @@
+package rename
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"cfiler/internal/fileops"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// EditedMsg reports the names after editing them in $EDITOR. Results pair
+// each kept entry with its new name; Deleted lists entries whose lines
+// were removed. Existing is what Dir held when the editor closed.
+type EditedMsg struct {
+	Dir      string
+	Results  []Result
+	Deleted  []string
+	Existing Existing
+	Err      error
+}
+
+// Edit writes names, entries of dir, to a temporary file as numbered lines
+// and opens it in the user's editor while the TUI is suspended.
+func Edit(dir string, names []string) tea.Cmd {
+	path, err := writeList(names)
+	if err != nil {
+		return func() tea.Msg { return EditedMsg{Dir: dir, Err: err} }
+	}
+	return tea.ExecProcess(fileops.EditCommand(path), func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return EditedMsg{Dir: dir, Err: fmt.Errorf("editor: %w", err)}
+		}
+		results, deleted, err := readList(path, names)
+		var existing Existing
+		if err == nil {
+			existing, err = List(dir)
+		}
+		if err == nil {
+			Check(existing, results, deleted)
+		}
+		return EditedMsg{Dir: dir, Results: results, Deleted: deleted, Existing: existing, Err: err}
+	})
+}
+
+func writeList(names []string) (string, error) {
+	f, err := os.CreateTemp("", "cfiler-rename-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	width := len(strconv.Itoa(len(names)))
+	w := bufio.NewWriter(f)
+	for i, name := range names {
+		if strings.ContainsAny(name, "\n\r") {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("cannot edit a name containing a line break: %q", name)
+		}
+		fmt.Fprintf(w, "%0*d\t%s\n", width, i+1, name)
+	}
+	if err := w.Flush(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// readList parses the edited file. Every line must still start with the
+// number it was given; entries whose line is gone are reported deleted.
+func readList(path string, names []string) (results []Result, deleted []string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[int]bool)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		num, name, ok := strings.Cut(line, "\t")
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || err != nil || n < 1 || n > len(names) {
+			return nil, nil, fmt.Errorf("line %d: expected a number, a tab and a name", i+1)
+		}
+		if seen[n] {
+			return nil, nil, fmt.Errorf("line %d: number %d appears twice", i+1, n)
+		}
+		seen[n] = true
+		results = append(results, Result{Old: names[n-1], New: name})
+	}
+	for i, name := range names {
+		if !seen[i+1] {
+			deleted = append(deleted, name)
+		}
+	}
+	return results, deleted, nil
+}
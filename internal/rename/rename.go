@@ -0,0 +1,267 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"cfiler/internal/fileops"
+	"cfiler/internal/vfs"
+)
+
+// Case is a case conversion applied to the new names.
+type Case int
+
+const (
+	CaseKeep Case = iota
+	CaseLower
+	CaseUpper
+	CaseTitle
+)
+
+func (c Case) String() string {
+	switch c {
+	case CaseLower:
+		return "lower"
+	case CaseUpper:
+		return "UPPER"
+	case CaseTitle:
+		return "Title"
+	}
+	return "keep"
+}
+
+// Rule describes how new names are derived from old ones. Find is replaced
+// by Replace first, as a regular expression with $1-style groups when
+// Regex is set. Template then builds the name from {name} and {ext} of the
+// result and the counter {n}, which may be zero padded as in {n:03}. An
+// empty template keeps the name as it is.
+type Rule struct {
+	Find     string
+	Replace  string
+	Regex    bool
+	Template string
+	Case     Case
+}
+
+// Result is one planned rename. Problem says why it cannot be applied.
+type Result struct {
+	Old     string
+	New     string
+	Problem string
+}
+
+func (r Result) Changed() bool {
+	return r.New != r.Old
+}
+
+var placeholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ValidName reports whether name can name an entry of a directory on its
+// own, without pointing into another one.
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`+"\n")
+}
+
+// Existing holds the names in a directory. It is listed once, so checking
+// a preview on every keystroke does not go back to the filesystem, which
+// for a remote directory is a round trip per name.
+type Existing map[string]bool
+
+// List reads the names in dir.
+func List(dir string) (Existing, error) {
+	infos, err := vfs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(Existing, len(infos))
+	for _, info := range infos {
+		existing[info.Name()] = true
+	}
+	return existing, nil
+}
+
+// Preview applies rule to names, entries of a directory holding existing,
+// and checks the new names against each other and against existing.
+func Preview(existing Existing, names []string, rule Rule) ([]Result, error) {
+	var re *regexp.Regexp
+	if rule.Regex && rule.Find != "" {
+		var err error
+		if re, err = regexp.Compile(rule.Find); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range placeholder.FindAllStringSubmatch(rule.Template, -1) {
+		if _, _, err := parsePlaceholder(m[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]Result, len(names))
+	for i, name := range names {
+		n := name
+		switch {
+		case re != nil:
+			n = re.ReplaceAllString(n, rule.Replace)
+		case rule.Find != "":
+			n = strings.ReplaceAll(n, rule.Find, rule.Replace)
+		}
+		if rule.Template != "" {
+			n = expand(rule.Template, n, i+1)
+		}
+		results[i] = Result{Old: name, New: convertCase(n, rule.Case)}
+	}
+	Check(existing, results, nil)
+	return results, nil
+}
+
+// parsePlaceholder splits "n:03" into its key and width.
+func parsePlaceholder(s string) (key string, width int, err error) {
+	key, format, hasFormat := strings.Cut(s, ":")
+	switch key {
+	case "name", "ext":
+		if hasFormat {
+			return "", 0, fmt.Errorf("{%s} takes no format", key)
+		}
+	case "n":
+		if hasFormat {
+			if width, err = strconv.Atoi(format); err != nil || width < 0 {
+				return "", 0, fmt.Errorf("bad counter width in {%s}", s)
+			}
+		}
+	default:
+		return "", 0, fmt.Errorf("unknown placeholder {%s}", s)
+	}
+	return key, width, nil
+}
+
+func expand(template, name string, n int) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	return placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		key, width, _ := parsePlaceholder(m[1 : len(m)-1])
+		switch key {
+		case "name":
+			return stem
+		case "ext":
+			return ext
+		}
+		return fmt.Sprintf("%0*d", width, n)
+	})
+}
+
+func convertCase(s string, c Case) string {
+	switch c {
+	case CaseLower:
+		return strings.ToLower(s)
+	case CaseUpper:
+		return strings.ToUpper(s)
+	case CaseTitle:
+		r := []rune(strings.ToLower(s))
+		for i := range r {
+			if i == 0 || !unicode.IsLetter(r[i-1]) && !unicode.IsDigit(r[i-1]) {
+				r[i] = unicode.ToUpper(r[i])
+			}
+		}
+		return string(r)
+	}
+	return s
+}
+
+// Check sets Problem on results that would fail or clobber something in
+// the directory holding existing. Entries named in deleted are going away
+// first, so their names are free.
+func Check(existing Existing, results []Result, deleted []string) {
+	freed := make(map[string]bool) // names that are renamed away
+	for _, name := range deleted {
+		freed[name] = true
+	}
+	count := make(map[string]int)
+	for _, r := range results {
+		if r.Changed() {
+			freed[r.Old] = true
+		}
+		count[r.New]++
+	}
+
+	for i := range results {
+		r := &results[i]
+		r.Problem = ""
+		if !r.Changed() {
+			continue
+		}
+		switch {
+		case !ValidName(r.New):
+			r.Problem = "invalid name"
+		case count[r.New] > 1:
+			r.Problem = "duplicate name"
+		case !freed[r.New] && existing[r.New]:
+			// A name that differs from another entry only in case is
+			// not caught on a case-insensitive filesystem; RenameAll
+			// still refuses to replace it.
+			r.Problem = "already exists"
+		}
+	}
+}
+
+// Steps orders the changed results so that each target is free when its
+// turn comes: a rename onto another entry's old name waits until that
+// entry has moved away. Renames that wait on each other in a cycle, such
+// as a swap, are broken up by moving one of them to a temporary name in
+// dir first.
+func Steps(dir string, results []Result) []fileops.Step {
+	var queue []Result
+	pending := make(map[string]bool) // old names still to be renamed
+	for _, r := range results {
+		if r.Changed() {
+			queue = append(queue, r)
+			pending[r.Old] = true
+		}
+	}
+
+	var steps []fileops.Step
+	add := func(from, to string) {
+		steps = append(steps, fileops.Step{From: vfs.Join(dir, from), To: vfs.Join(dir, to)})
+	}
+	for len(queue) > 0 {
+		var blocked []Result
+		for _, r := range queue {
+			if pending[r.New] {
+				blocked = append(blocked, r)
+				continue
+			}
+			add(r.Old, r.New)
+			delete(pending, r.Old)
+		}
+		if len(blocked) == len(queue) {
+			// Everything left waits on something else: a cycle.
+			r := blocked[0]
+			tmp := tempName(dir, pending)
+			add(r.Old, tmp)
+			delete(pending, r.Old)
+			pending[tmp] = true
+			blocked[0] = Result{Old: tmp, New: r.New}
+		}
+		queue = blocked
+	}
+	return steps
+}
+
+// tempName returns a name in dir for parking an entry during a cycle that
+// is neither taken nor already parking another entry.
+func tempName(dir string, pending map[string]bool) string {
+	for i := 0; ; i++ {
+		name := fmt.Sprintf(".cfiler-rename-%d", i)
+		if pending[name] {
+			continue
+		}
+		if _, err := vfs.Lstat(vfs.Join(dir, name)); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+	}
+}
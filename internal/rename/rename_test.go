@@ -0,0 +1,101 @@
+package rename
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cfiler/internal/fileops"
+	"cfiler/internal/sftpfs/sftptest"
+	"cfiler/internal/vfs"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// remoteDir serves a temporary directory over SFTP in the same process
+// and returns it as a remote location along with its local path.
+func remoteDir(t *testing.T) (loc, dir string) {
+	t.Helper()
+	sftptest.Register(t, &sftptest.Server{})
+	dir = t.TempDir()
+	return sftptest.Loc(dir), dir
+}
+
+func TestRemoteDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	loc, dir := remoteDir(t)
+	for _, name := range []string{"a.txt", "b.txt", "taken.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	existing, err := List(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Preview(existing, []string{"a.txt"}, Rule{Find: "a", Replace: "taken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Problem != "already exists" {
+		t.Errorf("rename onto a remote entry: problem %q, want %q", results[0].Problem, "already exists")
+	}
+
+	// Swapping the two names needs a temporary name in the remote dir.
+	swap := []Result{{Old: "a.txt", New: "b.txt"}, {Old: "b.txt", New: "a.txt"}}
+	Check(existing, swap, nil)
+	for _, r := range swap {
+		if r.Problem != "" {
+			t.Fatalf("%s: %s", r.Old, r.Problem)
+		}
+	}
+	steps := Steps(loc, swap)
+	for _, s := range steps {
+		if !strings.HasPrefix(s.From, loc+"/") || !strings.HasPrefix(s.To, loc+"/") {
+			t.Fatalf("step %s -> %s is not in %s", s.From, s.To, loc)
+		}
+	}
+	if err := fileops.RenameAll(steps); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.txt": "b.txt", "b.txt": "a.txt"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s holds %q (%v), want %q", name, data, err, want)
+		}
+	}
+}
+
+// Typing in the overlay checks the preview against the listing taken when
+// it opened, without a round trip to the server per name.
+func TestViewListsOnce(t *testing.T) {
+	loc, dir := remoteDir(t)
+	for _, name := range []string{"a.txt", "taken.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewModel(loc, []string{"a.txt"}, 100, 40)
+
+	vfs.Register("sftp", func(*url.URL) (vfs.FS, error) {
+		t.Error("the preview went to the server")
+		return nil, errors.New("offline")
+	})
+	for _, r := range "a" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	for _, r := range "taken" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if len(m.results) != 1 || m.results[0].New != "taken.txt" || m.results[0].Problem != "already exists" {
+		t.Errorf("preview %+v, want taken.txt already existing", m.results)
+	}
+}
@@ -0,0 +1,230 @@
+package rename
+
+import (
+	"fmt"
+	"strings"
+
+	"cfiler/internal/fileops"
+	"cfiler/internal/fit"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ApplyMsg asks the app to perform the renames in Steps, in order.
+type ApplyMsg struct {
+	Steps []fileops.Step
+}
+
+type CloseMsg struct{}
+
+const (
+	fieldFind = iota
+	fieldReplace
+	fieldTemplate
+	fieldCount
+)
+
+// Model is the batch rename overlay: a rule editor above a live preview
+// of old and new names.
+type Model struct {
+	dir      string
+	names    []string
+	existing Existing
+	listErr  error
+	inputs   [fieldCount]textinput.Model
+	focus    int
+	regex    bool
+	nameCase Case
+	results  []Result
+	err      error
+	offset   int
+	width    int
+	height   int
+}
+
+func NewModel(dir string, names []string, width, height int) Model {
+	m := Model{dir: dir, names: names, width: width, height: height}
+	placeholders := [fieldCount]string{"find", "replace with", "{name}{ext}"}
+	for i := range m.inputs {
+		ti := textinput.New()
+		ti.Placeholder = placeholders[i]
+		ti.CharLimit = 256
+		ti.Width = width*3/4 - 20
+		m.inputs[i] = ti
+	}
+	m.inputs[fieldFind].Focus()
+	m.existing, m.listErr = List(dir)
+	m.refresh()
+	return m
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch keyMsg.String() {
+	case "esc":
+		return m, func() tea.Msg { return CloseMsg{} }
+	case "enter":
+		if steps, ok := m.steps(); ok {
+			return m, func() tea.Msg { return ApplyMsg{Steps: steps} }
+		}
+		return m, nil
+	case "tab", "shift+tab":
+		m.inputs[m.focus].Blur()
+		if keyMsg.String() == "tab" {
+			m.focus = (m.focus + 1) % fieldCount
+		} else {
+			m.focus = (m.focus + fieldCount - 1) % fieldCount
+		}
+		return m, m.inputs[m.focus].Focus()
+	case "ctrl+r":
+		m.regex = !m.regex
+		m.refresh()
+		return m, nil
+	case "ctrl+t":
+		m.nameCase = (m.nameCase + 1) % (CaseTitle + 1)
+		m.refresh()
+		return m, nil
+	case "up":
+		if m.offset > 0 {
+			m.offset--
+		}
+		return m, nil
+	case "down":
+		if m.offset < len(m.results)-m.visibleLines() {
+			m.offset++
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	m.refresh()
+	return m, cmd
+}
+
+func (m *Model) refresh() {
+	if m.listErr != nil {
+		m.results, m.err = nil, m.listErr
+		return
+	}
+	m.results, m.err = Preview(m.existing, m.names, Rule{
+		Find:     m.inputs[fieldFind].Value(),
+		Replace:  m.inputs[fieldReplace].Value(),
+		Regex:    m.regex,
+		Template: m.inputs[fieldTemplate].Value(),
+		Case:     m.nameCase,
+	})
+}
+
+// steps returns the renames to apply, or false if there is nothing to do
+// or a problem has to be fixed first.
+func (m Model) steps() ([]fileops.Step, bool) {
+	if m.err != nil || m.problems() > 0 {
+		return nil, false
+	}
+	steps := Steps(m.dir, m.results)
+	return steps, len(steps) > 0
+}
+
+func (m Model) problems() int {
+	n := 0
+	for _, r := range m.results {
+		if r.Problem != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (m Model) visibleLines() int {
+	// border, padding, title, fields, header, status and footer
+	h := m.height - 18
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+func (m Model) View() string {
+	dialogW := m.width * 3 / 4
+	if dialogW < 60 {
+		dialogW = 60
+	}
+	if dialogW > m.width-4 {
+		dialogW = m.width - 4
+	}
+	innerW := dialogW - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#7aa2f7")).
+		Width(10)
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+	errStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#db4b4b"))
+	newStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#9ece6a"))
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Rename %d items", len(m.names))))
+	b.WriteString("\n\n")
+
+	labels := [fieldCount]string{"Find", "Replace", "Template"}
+	for i, input := range m.inputs {
+		b.WriteString(labelStyle.Render(labels[i]))
+		b.WriteString(input.View())
+		b.WriteString("\n")
+	}
+	regex := "off"
+	if m.regex {
+		regex = "on"
+	}
+	b.WriteString(dimStyle.Render(fmt.Sprintf("Regex: %s (Ctrl+R)  Case: %s (Ctrl+T)", regex, m.nameCase)))
+	b.WriteString("\n\n")
+
+	colW := (innerW - 3) / 2
+	end := m.offset + m.visibleLines()
+	if end > len(m.results) {
+		end = len(m.results)
+	}
+	for i := m.offset; i < end; i++ {
+		r := m.results[i]
+		line := dimStyle.Render(fit.Pad(r.Old, colW)) + " → "
+		switch {
+		case r.Problem != "":
+			line += errStyle.Render(fit.Pad(r.New+"  ("+r.Problem+")", colW))
+		case r.Changed():
+			line += newStyle.Render(fit.Pad(r.New, colW))
+		default:
+			line += dimStyle.Render(fit.Pad(r.New, colW))
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	switch n := m.problems(); {
+	case m.err != nil:
+		b.WriteString(errStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+	case n > 0:
+		b.WriteString(errStyle.Render(fmt.Sprintf("%d names need fixing", n)))
+	default:
+		b.WriteString(dimStyle.Render("Enter: apply  Tab: next field  ↑/↓: scroll  Esc: cancel"))
+	}
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(b.String())
+}
@@ -0,0 +1,166 @@
+package sftpfs
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const dialTimeout = 15 * time.Second
+
+// keyFiles are the private keys in ~/.ssh that are tried after the agent.
+// Keys protected by a passphrase are skipped; load them into ssh-agent.
+var keyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// dial connects to the host named by u over SSH and starts SFTP on it. The
+// host key must already be in ~/.ssh/known_hosts.
+func dial(u *url.URL) (*FS, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot check host keys: %w", err)
+	}
+
+	name := u.User.Username()
+	if name == "" {
+		cur, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		name = cur.Username
+	}
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	signers, closeAgent := loadSigners(home)
+	defer closeAgent()
+	if len(signers) == 0 {
+		return nil, errors.New("no SSH keys found in ssh-agent or ~/.ssh")
+	}
+	config := &ssh.ClientConfig{
+		User:              name,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback:   hostKeys,
+		HostKeyAlgorithms: knownAlgorithms(hostKeys, addr),
+		Timeout:           dialTimeout,
+	}
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("%s is not in ~/.ssh/known_hosts; connect once with ssh to add it", u.Hostname())
+		}
+		return nil, err
+	}
+	client, err := sftpClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client.closer = conn
+	return client, nil
+}
+
+// sftpClient starts the sftp subsystem on a new session of conn.
+func sftpClient(conn *ssh.Client) (*FS, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("the server does not offer SFTP: %w", err)
+	}
+	return New(sessionConn{Reader: r, WriteCloser: w, session: session})
+}
+
+// sessionConn joins the pipes of an SSH session into one connection.
+type sessionConn struct {
+	io.Reader
+	io.WriteCloser
+	session *ssh.Session
+}
+
+func (c sessionConn) Close() error {
+	c.WriteCloser.Close()
+	return c.session.Close()
+}
+
+// loadSigners collects the keys offered by ssh-agent, then those in
+// ~/.ssh. The returned function closes the agent connection once the
+// keys are no longer needed.
+func loadSigners(home string) ([]ssh.Signer, func()) {
+	var signers []ssh.Signer
+	closeAgent := func() {}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closeAgent = func() { conn.Close() }
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+	for _, name := range keyFiles {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	return signers, closeAgent
+}
+
+// knownAlgorithms lists the types of the keys known_hosts holds for addr,
+// so the server is asked for one of those rather than its first choice.
+// It is empty for hosts not in the file.
+func knownAlgorithms(hostKeys ssh.HostKeyCallback, addr string) []string {
+	// A key that matches nothing makes the callback list the known ones.
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if err := hostKeys(addr, &net.TCPAddr{}, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algos []string
+	for _, known := range keyErr.Want {
+		switch t := known.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			// RSA keys are used with the SHA-2 signature algorithms.
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, t)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	return algos
+}
@@ -0,0 +1,22 @@
+package sftpfs
+
+import (
+	"net/url"
+	"testing"
+)
+
+// SetConnect replaces how Open connects to a host until the test ends.
+func SetConnect(t testing.TB, fn func(*url.URL) (*FS, error)) {
+	connect = fn
+	t.Cleanup(func() {
+		CloseAll()
+		connect = dial
+	})
+}
+
+func (f *FS) Alive() bool { return f.alive() }
+
+func (f *FS) HasExtension(name string) bool {
+	_, ok := f.client.HasExtension(name)
+	return ok
+}
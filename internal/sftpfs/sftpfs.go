@@ -0,0 +1,215 @@
+// Package sftpfs is the vfs backend for sftp://user@host[:port]/path
+// locations. Connections are made over SSH on first use and kept open for
+// later locations on the same host.
+package sftpfs
+
+import (
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"cfiler/internal/vfs"
+
+	"github.com/pkg/sftp"
+)
+
+// FS is one SFTP session. Locations passed to it must be on its host.
+type FS struct {
+	client *sftp.Client
+	closer io.Closer // the SSH connection under client, if any
+
+	mu   sync.Mutex
+	lost bool
+}
+
+// New runs an SFTP session over conn, which carries the SFTP protocol
+// itself: an SSH subsystem channel, or a pipe to a server in the same
+// process.
+func New(conn io.ReadWriteCloser) (*FS, error) {
+	client, err := sftp.NewClientPipe(conn, conn, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		return nil, err
+	}
+	f := &FS{client: client}
+	go f.watch()
+	return f, nil
+}
+
+// watch marks the session as lost once its connection ends, so the next
+// location on the host connects again.
+func (f *FS) watch() {
+	f.client.Wait()
+	f.mu.Lock()
+	f.lost = true
+	f.mu.Unlock()
+}
+
+func (f *FS) alive() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.lost
+}
+
+// Close ends the session and the connection under it.
+func (f *FS) Close() error {
+	err := f.client.Close()
+	if f.closer != nil {
+		if cerr := f.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// host holds the session to one user@host. Its lock is held while
+// connecting, so a slow host only holds up locations on that host.
+type host struct {
+	mu sync.Mutex
+	fs *FS
+}
+
+var (
+	hostsMu sync.Mutex
+	hosts   = make(map[string]*host)
+
+	connect = dial // replaced in tests
+)
+
+// Open returns the session for the host named by u, connecting if there
+// is none yet or the last one was lost. It is the vfs.Opener for "sftp".
+func Open(u *url.URL) (vfs.FS, error) {
+	key := u.User.Username() + "@" + u.Host
+	hostsMu.Lock()
+	h := hosts[key]
+	if h == nil {
+		h = &host{}
+		hosts[key] = h
+	}
+	hostsMu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fs != nil {
+		if h.fs.alive() {
+			return h.fs, nil
+		}
+		h.fs.Close()
+		h.fs = nil
+	}
+	f, err := connect(u)
+	if err != nil {
+		return nil, err
+	}
+	h.fs = f
+	return f, nil
+}
+
+// CloseAll ends every open session.
+func CloseAll() {
+	hostsMu.Lock()
+	all := hosts
+	hosts = make(map[string]*host)
+	hostsMu.Unlock()
+	for _, h := range all {
+		h.mu.Lock()
+		if h.fs != nil {
+			h.fs.Close()
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (f *FS) ReadDir(dir string) ([]fs.FileInfo, error) {
+	return f.client.ReadDir(vfs.Path(dir))
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.client.Stat(vfs.Path(name))
+}
+
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	return f.client.Lstat(vfs.Path(name))
+}
+
+func (f *FS) Readlink(name string) (string, error) {
+	return f.client.ReadLink(vfs.Path(name))
+}
+
+// Open returns the remote file. Reads of more than one packet are sent
+// concurrently, so callers should read in large blocks.
+func (f *FS) Open(name string) (io.ReadCloser, error) {
+	return f.client.Open(vfs.Path(name))
+}
+
+func (f *FS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	file, err := f.client.OpenFile(vfs.Path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		if _, serr := f.client.Lstat(vfs.Path(name)); serr == nil {
+			return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+		}
+		return nil, err
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		f.client.Remove(vfs.Path(name))
+		return nil, err
+	}
+	return file, nil
+}
+
+// Rename replaces an existing file at newname. Plain SFTP renames refuse
+// to, so without the posix-rename extension the file is moved aside first
+// and removed once the rename is done.
+func (f *FS) Rename(oldname, newname string) error {
+	oldp, newp := vfs.Path(oldname), vfs.Path(newname)
+	if _, ok := f.client.HasExtension("posix-rename@openssh.com"); ok {
+		return f.client.PosixRename(oldp, newp)
+	}
+	info, err := f.client.Lstat(newp)
+	if err != nil || info.IsDir() {
+		return f.client.Rename(oldp, newp)
+	}
+	// Named like an unfinished copy, so a leftover is cleaned up with them.
+	aside := path.Join(path.Dir(newp), ".cfiler-"+path.Base(newp)+".replaced.part")
+	f.client.Remove(aside)
+	if err := f.client.Rename(newp, aside); err != nil {
+		return err
+	}
+	if err := f.client.Rename(oldp, newp); err != nil {
+		f.client.Rename(aside, newp)
+		return err
+	}
+	return f.client.Remove(aside)
+}
+
+func (f *FS) Remove(name string) error {
+	return f.client.Remove(vfs.Path(name))
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	p := vfs.Path(name)
+	if err := f.client.Mkdir(p); err != nil {
+		// Servers report an existing directory as a generic failure.
+		if info, serr := f.client.Lstat(p); serr == nil && info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+		}
+		return err
+	}
+	return f.client.Chmod(p, perm)
+}
+
+func (f *FS) Symlink(target, name string) error {
+	return f.client.Symlink(target, vfs.Path(name))
+}
+
+func (f *FS) Chmod(name string, mode fs.FileMode) error {
+	return f.client.Chmod(vfs.Path(name), mode)
+}
+
+func (f *FS) Chtimes(name string, atime, mtime time.Time) error {
+	return f.client.Chtimes(vfs.Path(name), atime, mtime)
+}
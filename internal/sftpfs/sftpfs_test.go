@@ -0,0 +1,293 @@
+package sftpfs_test
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cfiler/internal/fileops"
+	"cfiler/internal/sftpfs"
+	"cfiler/internal/sftpfs/sftptest"
+	"cfiler/internal/vfs"
+
+	"github.com/pkg/sftp"
+)
+
+// useServer routes sftp:// locations through Open to s for the rest of
+// the test.
+func useServer(t *testing.T, s *sftptest.Server) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // for the undo journal
+	sftpfs.SetConnect(t, s.Connect)
+	vfs.Register("sftp", sftpfs.Open)
+}
+
+func writeFile(t *testing.T, path, data string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkFile(t *testing.T, path, data string, mtime time.Time) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("%s holds %q, want %q", path, got, data)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("%s has mode %v, want %v", path, info.Mode().Perm(), os.FileMode(0640))
+	}
+	// SFTP carries times in whole seconds.
+	if !info.ModTime().Equal(mtime.Truncate(time.Second)) {
+		t.Errorf("%s modified %v, want %v", path, info.ModTime(), mtime)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	useServer(t, &sftptest.Server{})
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "file"), "data", time.Now())
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := vfs.ReadDir(sftptest.Loc(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes := make(map[string]os.FileMode)
+	for _, info := range infos {
+		modes[info.Name()] = info.Mode()
+	}
+	if len(modes) != 3 || !modes["file"].IsRegular() || !modes["sub"].IsDir() || modes["link"]&os.ModeSymlink == 0 {
+		t.Errorf("listed %v", modes)
+	}
+	if target, err := vfs.Readlink(sftptest.Loc(filepath.Join(dir, "link"))); err != nil || target != "file" {
+		t.Errorf("link points to %q (%v)", target, err)
+	}
+
+	rc, err := vfs.Open(sftptest.Loc(filepath.Join(dir, "file")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if data, err := io.ReadAll(rc); err != nil || string(data) != "data" {
+		t.Errorf("read %q (%v)", data, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	useServer(t, &sftptest.Server{})
+	ctx := context.Background()
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	local, far, back := t.TempDir(), t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(local, "tree", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(local, "tree", "sub", "file"), "data", mtime)
+
+	if err := fileops.Copy(ctx, filepath.Join(local, "tree"), sftptest.Loc(far), fileops.Options{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(far, "tree", "sub", "file"), "data", mtime)
+
+	if err := fileops.Copy(ctx, sftptest.Loc(filepath.Join(far, "tree")), back, fileops.Options{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(back, "tree", "sub", "file"), "data", mtime)
+}
+
+func TestMove(t *testing.T) {
+	useServer(t, &sftptest.Server{})
+	ctx := context.Background()
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	local, far := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(local, "file"), "data", mtime)
+	if err := os.Mkdir(filepath.Join(far, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fileops.Move(ctx, filepath.Join(local, "file"), sftptest.Loc(far), fileops.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(local, "file")); !os.IsNotExist(err) {
+		t.Errorf("source left behind: %v", err)
+	}
+	checkFile(t, filepath.Join(far, "file"), "data", mtime)
+
+	// Within the host the file is renamed.
+	if err := fileops.Move(ctx, sftptest.Loc(filepath.Join(far, "file")), sftptest.Loc(filepath.Join(far, "sub")), fileops.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(far, "sub", "file"), "data", mtime)
+}
+
+func TestOverwrite(t *testing.T) {
+	useServer(t, &sftptest.Server{})
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	local, far := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(local, "file"), "new", mtime)
+	writeFile(t, filepath.Join(far, "file"), "old", time.Now())
+
+	err := fileops.Copy(context.Background(), filepath.Join(local, "file"), sftptest.Loc(far), fileops.Options{Resolve: overwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(far, "file"), "new", mtime)
+	if entries, _ := os.ReadDir(far); len(entries) != 1 {
+		t.Errorf("left %d entries, want 1", len(entries))
+	}
+}
+
+// Plain SFTP servers refuse to rename onto an existing file, so without
+// posix-rename the file in the way is replaced by hand.
+func TestOverwriteWithoutPosixRename(t *testing.T) {
+	sftptest.WithoutExtensions(t)
+	mem := sftp.InMemHandler()
+	useServer(t, &sftptest.Server{Mem: &mem})
+	local := t.TempDir()
+	writeFile(t, filepath.Join(local, "file"), "new", time.Now())
+	putRemote(t, "sftp://me@host/file", "old")
+
+	fsys, err := vfs.Resolve("sftp://me@host/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsys.(*sftpfs.FS).HasExtension("posix-rename@openssh.com") {
+		t.Fatal("server still offers posix-rename")
+	}
+
+	err = fileops.Copy(context.Background(), filepath.Join(local, "file"), "sftp://me@host/", fileops.Options{Resolve: overwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getRemote(t, "sftp://me@host/file"); got != "new" {
+		t.Errorf("file holds %q, want %q", got, "new")
+	}
+
+	// Renaming a file onto another replaces it too.
+	putRemote(t, "sftp://me@host/other", "other")
+	if err := vfs.Rename("sftp://me@host/other", "sftp://me@host/file"); err != nil {
+		t.Fatal(err)
+	}
+	if got := getRemote(t, "sftp://me@host/file"); got != "other" {
+		t.Errorf("file holds %q, want %q", got, "other")
+	}
+	infos, err := vfs.ReadDir("sftp://me@host/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Errorf("left %d entries, want 1", len(infos))
+	}
+}
+
+func overwrite(context.Context, fileops.Conflict) (fileops.ConflictAction, error) {
+	return fileops.ConflictOverwrite, nil
+}
+
+func putRemote(t *testing.T, loc, data string) {
+	t.Helper()
+	w, err := vfs.Create(loc, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getRemote(t *testing.T, loc string) string {
+	t.Helper()
+	r, err := vfs.Open(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReconnect(t *testing.T) {
+	s := &sftptest.Server{}
+	useServer(t, s)
+	dir := t.TempDir()
+
+	first, err := vfs.Resolve(sftptest.Loc(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := vfs.Resolve(sftptest.Loc(dir)); again != first {
+		t.Error("second location on the host connected again")
+	}
+
+	s.Drop()
+	deadline := time.Now().Add(5 * time.Second)
+	for first.(*sftpfs.FS).Alive() {
+		if time.Now().After(deadline) {
+			t.Fatal("dropped connection not noticed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := vfs.ReadDir(sftptest.Loc(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Dials(); n != 2 {
+		t.Errorf("connected %d times, want 2", n)
+	}
+}
+
+func TestSlowHost(t *testing.T) {
+	s := &sftptest.Server{}
+	useServer(t, s)
+	release := make(chan struct{})
+	sftpfs.SetConnect(t, func(u *url.URL) (*sftpfs.FS, error) {
+		if u.Hostname() == "slow" {
+			<-release
+		}
+		return s.Connect(u)
+	})
+	defer close(release)
+
+	go vfs.Resolve("sftp://me@slow/")
+	time.Sleep(10 * time.Millisecond) // let it start connecting
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := vfs.ReadDir(sftptest.Loc(t.TempDir()))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow host held up another")
+	}
+}
@@ -0,0 +1,93 @@
+// Package sftptest serves SFTP inside the test process, for tests of code
+// that reaches remote locations through vfs.
+package sftptest
+
+import (
+	"net"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cfiler/internal/sftpfs"
+	"cfiler/internal/vfs"
+
+	"github.com/pkg/sftp"
+)
+
+// Server stands in for the SSH connection to a host: every connection is
+// a pipe to an SFTP server serving the local disk, or with Mem set a file
+// tree in memory. Unlike the disk server, which renames onto existing
+// files, the memory server refuses to, as plain SFTP servers do.
+type Server struct {
+	Mem *sftp.Handlers
+
+	mu    sync.Mutex
+	dials int
+	conns []net.Conn // server ends, to drop connections
+}
+
+// Connect opens a new connection to the server. It has the signature of
+// an sftpfs dialer; the location is not looked at.
+func (s *Server) Connect(*url.URL) (*sftpfs.FS, error) {
+	client, conn := net.Pipe()
+	if s.Mem != nil {
+		go sftp.NewRequestServer(conn, *s.Mem).Serve()
+	} else {
+		srv, err := sftp.NewServer(conn)
+		if err != nil {
+			return nil, err
+		}
+		go srv.Serve()
+	}
+	s.mu.Lock()
+	s.dials++
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+	return sftpfs.New(client)
+}
+
+// Dials returns how many connections have been made.
+func (s *Server) Dials() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+// Drop closes every connection made so far from the server's end.
+func (s *Server) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Register routes every sftp:// location to one connection to s until
+// the test ends.
+func Register(t testing.TB, s *Server) {
+	t.Helper()
+	fsys, err := s.Connect(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fsys.Close() })
+	vfs.Register("sftp", func(*url.URL) (vfs.FS, error) { return fsys, nil })
+}
+
+// Loc returns the local directory dir as a location on the server.
+func Loc(dir string) string {
+	return "sftp://me@host" + filepath.ToSlash(dir)
+}
+
+// WithoutExtensions makes the servers started by the test offer no
+// extensions, such as posix-rename.
+func WithoutExtensions(t testing.TB) {
+	t.Helper()
+	if err := sftp.SetSFTPExtensions(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+	})
+}
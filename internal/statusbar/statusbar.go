@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"cfiler/internal/job"
 	"cfiler/internal/pane"
 
 	"github.com/charmbracelet/lipgloss"
@@ -28,7 +29,7 @@ func (m *Model) SetMessage(msg string, isError bool) {
 	m.isError = isError
 }
 
-func (m Model) View(activePane *pane.Model, searchMode bool, searchText string) string {
+func (m Model) View(activePane *pane.Model, searchMode bool, searchText string, jobs []job.Status) string {
 	style := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#c0caf5")).
 		Background(lipgloss.Color("#16161e")).
@@ -44,6 +45,11 @@ func (m Model) View(activePane *pane.Model, searchMode bool, searchText string)
 			if !entry.IsDir {
 				info += fmt.Sprintf(" | %d bytes", entry.Size)
 			}
+			if entry.Broken {
+				info += fmt.Sprintf(" | -> %s (broken)", entry.LinkTarget)
+			} else if entry.IsLink {
+				info += fmt.Sprintf(" | -> %s", entry.LinkTarget)
+			}
 			parts = append(parts, info)
 		}
 	}
@@ -52,6 +58,10 @@ func (m Model) View(activePane *pane.Model, searchMode bool, searchText string)
 		parts = append(parts, fmt.Sprintf(" | %d selected", activePane.MarkedCount()))
 	}
 
+	for _, j := range jobs {
+		parts = append(parts, " | "+j.Summary())
+	}
+
 	if searchMode {
 		parts = append(parts, fmt.Sprintf(" | Search: %s", searchText))
 	}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package trash
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func deviceOf(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("cannot determine device of %s", path)
+	}
+	return uint64(st.Dev), nil
+}
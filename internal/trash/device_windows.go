@@ -0,0 +1,7 @@
+//go:build windows
+
+package trash
+
+func deviceOf(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
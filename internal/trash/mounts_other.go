@@ -0,0 +1,7 @@
+//go:build !linux
+
+package trash
+
+func mountPoints() []string {
+	return nil
+}
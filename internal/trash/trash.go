@@ -0,0 +1,371 @@
+// Package trash implements the freedesktop.org Trash specification:
+// https://specifications.freedesktop.org/trash-spec/latest/
+package trash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	infoExt    = ".trashinfo"
+	dateLayout = "2006-01-02T15:04:05"
+)
+
+var ErrUnsupported = errors.New("trash is not supported on this platform")
+
+// Item is one trashed file or directory.
+type Item struct {
+	Name     string // name below files/ and info/
+	Dir      string // trash directory containing files/ and info/
+	OrigPath string
+	Deleted  time.Time
+}
+
+// Path returns where the trashed content currently lives.
+func (i Item) Path() string { return filepath.Join(i.Dir, "files", i.Name) }
+
+// InfoPath returns the location of the item's .trashinfo file.
+func (i Item) InfoPath() string { return filepath.Join(i.Dir, "info", i.Name+infoExt) }
+
+// Supported reports whether the platform uses the freedesktop trash.
+func Supported() bool {
+	return runtime.GOOS != "windows" && runtime.GOOS != "darwin"
+}
+
+// HomeDir returns the user's home trash, $XDG_DATA_HOME/Trash.
+func HomeDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// Put moves path into the trash of the volume it lives on.
+func Put(path string) (Item, error) {
+	if !Supported() {
+		return Item{}, ErrUnsupported
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Item{}, err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return Item{}, err
+	}
+
+	dir, topdir, err := trashDirFor(abs)
+	if err != nil {
+		return Item{}, err
+	}
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return Item{}, err
+		}
+	}
+
+	// Trashes on other volumes store paths relative to the volume's top
+	// directory, so the volume can be mounted elsewhere later.
+	stored := abs
+	if topdir != "" {
+		if rel, err := filepath.Rel(topdir, abs); err == nil {
+			stored = rel
+		}
+	}
+
+	now := time.Now()
+	item := Item{Dir: dir, OrigPath: abs, Deleted: now.Truncate(time.Second)}
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", escapePath(stored), now.Format(dateLayout))
+
+	base := filepath.Base(abs)
+	for n := 1; ; n++ {
+		item.Name = base
+		if n > 1 {
+			item.Name = fmt.Sprintf("%s.%d", base, n)
+		}
+		if _, err := os.Lstat(item.Path()); err == nil {
+			continue
+		}
+		// Creating the info file exclusively reserves the name.
+		f, err := os.OpenFile(item.InfoPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return Item{}, err
+		}
+		_, err = f.WriteString(info)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(item.InfoPath())
+			return Item{}, err
+		}
+		break
+	}
+
+	if err := os.Rename(abs, item.Path()); err != nil {
+		os.Remove(item.InfoPath())
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// trashDirFor picks the trash directory for abs. topdir is empty for the
+// home trash and the volume's mount point otherwise.
+func trashDirFor(abs string) (dir, topdir string, err error) {
+	dev, err := deviceOf(abs)
+	if err != nil {
+		return "", "", err
+	}
+
+	home, err := HomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	if homeDev, err := deviceOf(existingAncestor(home)); err == nil && homeDev == dev {
+		return home, "", nil
+	}
+
+	topdir, err = mountPoint(abs, dev)
+	if err != nil {
+		return "", "", err
+	}
+	uid := strconv.Itoa(os.Getuid())
+
+	// $topdir/.Trash/$uid is only used when an administrator set up
+	// .Trash as a sticky, non-symlinked directory.
+	shared := filepath.Join(topdir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dir = filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return dir, topdir, nil
+		}
+	}
+
+	dir = filepath.Join(topdir, ".Trash-"+uid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("no usable trash on this volume: %w", err)
+	}
+	return dir, topdir, nil
+}
+
+// mountPoint walks up from path to the topmost directory still on dev.
+func mountPoint(path string, dev uint64) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		parentDev, err := deviceOf(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentDev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// escapePath percent-encodes a path as required for the Path= key.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// List returns the items in the home trash and in the trashes of all
+// mounted volumes, newest first. Unreadable trashes are skipped.
+func List() ([]Item, error) {
+	if !Supported() {
+		return nil, ErrUnsupported
+	}
+	var items []Item
+	for _, d := range trashDirs() {
+		found, _ := listDir(d.dir, d.topdir)
+		items = append(items, found...)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Deleted.After(items[j].Deleted)
+	})
+	return items, nil
+}
+
+// Lookup returns the item whose content lives at path, a file below a
+// trash's files/ directory.
+func Lookup(path string) (Item, error) {
+	filesDir := filepath.Dir(path)
+	if filepath.Base(filesDir) != "files" {
+		return Item{}, fmt.Errorf("not in a trash: %s", path)
+	}
+	dir := filepath.Dir(filesDir)
+	return readInfo(dir, topdirOf(dir), filepath.Base(path))
+}
+
+// Remove deletes a trashed item permanently.
+func Remove(item Item) error {
+	if err := os.RemoveAll(item.Path()); err != nil {
+		return err
+	}
+	return os.Remove(item.InfoPath())
+}
+
+// Forget drops the metadata of an item whose content has already been
+// moved out of the trash.
+func Forget(item Item) error {
+	return os.Remove(item.InfoPath())
+}
+
+// Empty permanently deletes every item returned by List.
+func Empty() error {
+	items, err := List()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := Remove(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type trashDir struct {
+	dir    string
+	topdir string
+}
+
+func trashDirs() []trashDir {
+	var dirs []trashDir
+	if home, err := HomeDir(); err == nil {
+		dirs = append(dirs, trashDir{dir: home})
+	}
+	uid := strconv.Itoa(os.Getuid())
+	for _, top := range mountPoints() {
+		for _, dir := range []string{
+			filepath.Join(top, ".Trash", uid),
+			filepath.Join(top, ".Trash-"+uid),
+		} {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				dirs = append(dirs, trashDir{dir: dir, topdir: top})
+			}
+		}
+	}
+	return dirs
+}
+
+// topdirOf returns the volume top directory of a per-volume trash, or ""
+// for the home trash.
+func topdirOf(dir string) string {
+	if home, err := HomeDir(); err == nil && home == dir {
+		return ""
+	}
+	parent := filepath.Dir(dir)
+	if filepath.Base(parent) == ".Trash" {
+		return filepath.Dir(parent)
+	}
+	return parent
+}
+
+func listDir(dir, topdir string) ([]Item, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, "info"))
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), infoExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		item, err := readInfo(dir, topdir, name)
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func readInfo(dir, topdir, name string) (Item, error) {
+	item := Item{Name: name, Dir: dir}
+	f, err := os.Open(item.InfoPath())
+	if err != nil {
+		return Item{}, err
+	}
+	defer f.Close()
+
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == "[Trash Info]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inSection || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			p, err := url.PathUnescape(value)
+			if err != nil {
+				return Item{}, err
+			}
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(topdir, p)
+			}
+			item.OrigPath = p
+		case "DeletionDate":
+			if t, err := time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+				item.Deleted = t
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Item{}, err
+	}
+	if item.OrigPath == "" {
+		return Item{}, fmt.Errorf("%s: missing Path", item.InfoPath())
+	}
+	return item, nil
+}
@@ -0,0 +1,260 @@
+package trash
+
+import (
+	"fmt"
+	"strings"
+
+	"cfiler/internal/fit"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RestoreMsg asks the app to restore the trashed items at Paths.
+type RestoreMsg struct {
+	Paths []string
+}
+
+type CloseMsg struct{}
+
+// RemovedMsg reports the end of a permanent deletion started by the view.
+type RemovedMsg struct {
+	Err error
+}
+
+type confirmKind int
+
+const (
+	confirmNone confirmKind = iota
+	confirmDelete
+	confirmEmpty
+)
+
+// Model is the trash browser overlay.
+type Model struct {
+	items   []Item
+	cursor  int
+	offset  int
+	marked  map[int]bool
+	confirm confirmKind
+	err     error
+	width   int
+	height  int
+}
+
+func NewModel(width, height int) Model {
+	items, err := List()
+	return Model{
+		items:  items,
+		err:    err,
+		width:  width,
+		height: height,
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case RemovedMsg:
+		m.items, m.err = List()
+		if msg.Err != nil {
+			m.err = msg.Err
+		}
+		m.marked = nil
+		m.clampCursor()
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.confirm != confirmNone {
+			return m.updateConfirm(msg)
+		}
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case " ":
+			if m.cursor < len(m.items) {
+				if m.marked == nil {
+					m.marked = make(map[int]bool)
+				}
+				if m.marked[m.cursor] {
+					delete(m.marked, m.cursor)
+				} else {
+					m.marked[m.cursor] = true
+				}
+				if m.cursor < len(m.items)-1 {
+					m.cursor++
+				}
+			}
+		case "enter", "r":
+			selected := m.selected()
+			if len(selected) > 0 {
+				var paths []string
+				for _, item := range selected {
+					paths = append(paths, item.Path())
+				}
+				return m, func() tea.Msg { return RestoreMsg{Paths: paths} }
+			}
+		case "d", "delete":
+			if len(m.selected()) > 0 {
+				m.confirm = confirmDelete
+			}
+		case "E":
+			if len(m.items) > 0 {
+				m.confirm = confirmEmpty
+			}
+		case "esc", "T", "q":
+			return m, func() tea.Msg { return CloseMsg{} }
+		}
+	}
+	m.adjustOffset()
+	return m, nil
+}
+
+func (m Model) updateConfirm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	kind := m.confirm
+	m.confirm = confirmNone
+	if msg.String() != "y" && msg.String() != "Y" {
+		return m, nil
+	}
+	if kind == confirmEmpty {
+		return m, func() tea.Msg { return RemovedMsg{Err: Empty()} }
+	}
+	selected := m.selected()
+	return m, func() tea.Msg {
+		for _, item := range selected {
+			if err := Remove(item); err != nil {
+				return RemovedMsg{Err: err}
+			}
+		}
+		return RemovedMsg{}
+	}
+}
+
+// selected returns the marked items, or the item under the cursor.
+func (m Model) selected() []Item {
+	var items []Item
+	for i, item := range m.items {
+		if m.marked[i] {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 && m.cursor < len(m.items) {
+		items = append(items, m.items[m.cursor])
+	}
+	return items
+}
+
+func (m *Model) clampCursor() {
+	if m.cursor >= len(m.items) {
+		m.cursor = len(m.items) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	m.adjustOffset()
+}
+
+func (m *Model) adjustOffset() {
+	vis := m.visibleLines()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+vis {
+		m.offset = m.cursor - vis + 1
+	}
+}
+
+func (m Model) visibleLines() int {
+	// border, padding, title, blank lines and footer
+	h := m.height - 12
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+func (m Model) View() string {
+	dialogW := m.width * 3 / 4
+	if dialogW < 60 {
+		dialogW = 60
+	}
+	if dialogW > m.width-4 {
+		dialogW = m.width - 4
+	}
+	innerW := dialogW - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#bb9af7")).
+		Bold(true)
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#565f89"))
+	errStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#db4b4b"))
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Trash (%d items)", len(m.items))))
+	b.WriteString("\n\n")
+
+	if len(m.items) == 0 {
+		b.WriteString(dimStyle.Render("The trash is empty."))
+	} else {
+		const dateW = 16
+		nameW := innerW / 3
+		pathW := innerW - nameW - dateW - 6
+		end := m.offset + m.visibleLines()
+		if end > len(m.items) {
+			end = len(m.items)
+		}
+		for i := m.offset; i < end; i++ {
+			item := m.items[i]
+			cursor := "  "
+			if i == m.cursor {
+				cursor = "▸ "
+			}
+
+			nameStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#c0caf5"))
+			if m.marked[i] {
+				nameStyle = nameStyle.Foreground(lipgloss.Color("#e0af68")).Bold(true)
+			}
+
+			line := fmt.Sprintf("%s%s  %s  %s",
+				cursor,
+				nameStyle.Render(fit.Pad(item.Name, nameW)),
+				dimStyle.Render(fit.Pad(item.OrigPath, pathW)),
+				dimStyle.Render(item.Deleted.Format("2006-01-02 15:04")),
+			)
+			b.WriteString(line)
+			if i < end-1 {
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	if m.err != nil {
+		b.WriteString("\n\n")
+		b.WriteString(errStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+	}
+
+	b.WriteString("\n\n")
+	switch m.confirm {
+	case confirmDelete:
+		b.WriteString(errStyle.Render(fmt.Sprintf("Permanently delete %d items? [y/N]", len(m.selected()))))
+	case confirmEmpty:
+		b.WriteString(errStyle.Render(fmt.Sprintf("Permanently delete all %d items? [y/N]", len(m.items))))
+	default:
+		b.WriteString(dimStyle.Render("Enter/r: restore  Space: mark  d: delete  E: empty  Esc: close"))
+	}
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#bb9af7")).
+		Padding(1, 2).
+		Width(dialogW)
+
+	return boxStyle.Render(b.String())
+}
@@ -0,0 +1,102 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"cfiler/internal/archive"
+)
+
+// archives shows the contents of zip and tar files, read-only. Its
+// locations are paths through the archive file.
+var archives = archiveFS{}
+
+type archiveFS struct{}
+
+// entry looks up the archive entry at loc.
+func (archiveFS) entry(loc string) (archivePath string, e archive.Entry, err error) {
+	archivePath, inner, ok := archive.Split(loc)
+	if !ok {
+		return "", archive.Entry{}, &fs.PathError{Op: "stat", Path: loc, Err: fs.ErrNotExist}
+	}
+	e, err = archive.Stat(archivePath, inner)
+	return archivePath, e, err
+}
+
+func (a archiveFS) ReadDir(dir string) ([]fs.FileInfo, error) {
+	archivePath, inner, ok := archive.Split(dir)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: fs.ErrNotExist}
+	}
+	list, err := archive.List(archivePath, inner)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, len(list))
+	for i, e := range list {
+		infos[i] = e.Info()
+	}
+	return infos, nil
+}
+
+// Stat is the same as Lstat: links inside an archive are shown as links
+// and not followed.
+func (a archiveFS) Stat(name string) (fs.FileInfo, error) { return a.Lstat(name) }
+
+func (a archiveFS) Lstat(name string) (fs.FileInfo, error) {
+	_, e, err := a.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.Info(), nil
+}
+
+func (a archiveFS) Readlink(name string) (string, error) {
+	_, e, err := a.entry(name)
+	if err != nil {
+		return "", err
+	}
+	if e.Link == "" || e.Hardlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return e.Link, nil
+}
+
+// Open reads a file stored in the archive. A hard link reads the entry it
+// shares its contents with.
+func (a archiveFS) Open(name string) (io.ReadCloser, error) {
+	archivePath, e, err := a.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	inner := e.Name
+	if e.Hardlink {
+		inner = e.Link
+	}
+	return archive.Open(archivePath, inner)
+}
+
+func (archiveFS) Create(name string, _ fs.FileMode) (io.WriteCloser, error) {
+	return nil, readOnly("create", name)
+}
+func (archiveFS) Rename(_, newname string) error { return readOnly("rename", newname) }
+func (archiveFS) Remove(name string) error       { return readOnly("remove", name) }
+func (archiveFS) Mkdir(name string, _ fs.FileMode) error {
+	return readOnly("mkdir", name)
+}
+func (archiveFS) Symlink(_, name string) error { return readOnly("symlink", name) }
+func (archiveFS) Chmod(name string, _ fs.FileMode) error {
+	return readOnly("chmod", name)
+}
+func (archiveFS) Chtimes(name string, _, _ time.Time) error {
+	return readOnly("chtimes", name)
+}
+
+func readOnly(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"cfiler/internal/archive"
+)
+
+// Local is the local disk. Archive files on it can be listed as the
+// directory of their contents.
+var Local = localFS{}
+
+type localFS struct{}
+
+func (localFS) ReadDir(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if archive.Detect(dir) != archive.FormatNone {
+			if info, serr := os.Stat(dir); serr == nil && info.Mode().IsRegular() {
+				return archives.ReadDir(dir)
+			}
+		}
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		// Entries removed since the listing are left out.
+		if info, err := e.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func (localFS) Stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (localFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (localFS) Readlink(name string) (string, error)   { return os.Readlink(name) }
+func (localFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (localFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+}
+
+func (localFS) Rename(oldname, newname string) error      { return os.Rename(oldname, newname) }
+func (localFS) Remove(name string) error                  { return os.Remove(name) }
+func (localFS) Mkdir(name string, perm fs.FileMode) error { return os.Mkdir(name, perm) }
+func (localFS) Symlink(target, name string) error         { return os.Symlink(target, name) }
+func (localFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+func (localFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (localFS) removeAll(name string) error { return os.RemoveAll(name) }
+func (localFS) mkdirAll(dir string, perm fs.FileMode) error {
+	return os.MkdirAll(dir, perm)
+}
@@ -0,0 +1,98 @@
+package vfs
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// split separates a location into the part naming its backend, such as
+// "sftp://me@host", and the slash-separated path on it. Local paths have
+// no prefix.
+func split(loc string) (prefix, p string) {
+	i := strings.Index(loc, "://")
+	if i <= 0 || !isScheme(loc[:i]) {
+		return "", loc
+	}
+	rest := loc[i+len("://"):]
+	j := strings.IndexByte(rest, '/')
+	if j < 0 {
+		return loc, "/"
+	}
+	return loc[:i+len("://")+j], rest[j:]
+}
+
+func isScheme(s string) bool {
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Scheme returns the scheme of a remote location, and "" for local paths.
+func Scheme(loc string) string {
+	prefix, _ := split(loc)
+	if prefix == "" {
+		return ""
+	}
+	return prefix[:strings.Index(prefix, "://")]
+}
+
+// Path returns the path part of loc on its backend, such as "/srv/app"
+// for "sftp://me@host/srv/app". Local paths are returned unchanged.
+func Path(loc string) string {
+	_, p := split(loc)
+	return p
+}
+
+// Join joins path elements onto loc.
+func Join(loc string, elem ...string) string {
+	prefix, p := split(loc)
+	if prefix == "" {
+		return filepath.Join(append([]string{loc}, elem...)...)
+	}
+	return prefix + path.Join(append([]string{p}, elem...)...)
+}
+
+// Dir returns all but the last element of loc. The root of a remote
+// backend is its own parent, as "/" is locally.
+func Dir(loc string) string {
+	prefix, p := split(loc)
+	if prefix == "" {
+		return filepath.Dir(loc)
+	}
+	return prefix + path.Dir(p)
+}
+
+// Base returns the last element of loc.
+func Base(loc string) string {
+	prefix, p := split(loc)
+	if prefix == "" {
+		return filepath.Base(loc)
+	}
+	return path.Base(p)
+}
+
+// IsAbs reports whether loc is absolute; remote locations always are.
+func IsAbs(loc string) bool {
+	prefix, _ := split(loc)
+	return prefix != "" || filepath.IsAbs(loc)
+}
+
+// Clean returns the shortest form of loc. "file://" locations become
+// plain local paths.
+func Clean(loc string) string {
+	prefix, p := split(loc)
+	switch {
+	case prefix == "":
+		return filepath.Clean(loc)
+	case strings.EqualFold(Scheme(loc), "file"):
+		return filepath.Clean(filepath.FromSlash(p))
+	}
+	return prefix + path.Clean(p)
+}
@@ -0,0 +1,267 @@
+// Package vfs puts the places a pane can show behind one interface. A
+// location is a URI-like path that also names its backend: a plain path is
+// on the local disk, a path through an archive file is inside that archive,
+// and scheme://user@host/path is on a remote backend registered for the
+// scheme.
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"sync"
+	"time"
+
+	"cfiler/internal/archive"
+)
+
+// FS is a filesystem backend. Every name passed to it is a full location
+// that Resolve mapped to it.
+type FS interface {
+	// ReadDir lists the entries of dir, in no particular order. Symlinks
+	// are described as links, not by what they point to.
+	ReadDir(dir string) ([]fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+	Open(name string) (io.ReadCloser, error)
+
+	// Create makes a new file for writing, failing if name exists.
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	// Rename moves oldname to newname, replacing newname if it is a file.
+	Rename(oldname, newname string) error
+	// Remove removes a file or an empty directory.
+	Remove(name string) error
+	Mkdir(name string, perm fs.FileMode) error
+	Symlink(target, name string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// ErrReadOnly is returned by backends that cannot be changed.
+var ErrReadOnly = errors.New("read-only file system")
+
+// Opener connects to the backend for a remote location, given the part of
+// it that names the host. It may return the same FS for the same host.
+type Opener func(u *url.URL) (FS, error)
+
+var (
+	openersMu sync.Mutex
+	openers   = make(map[string]Opener)
+)
+
+// Register makes locations with scheme resolve through open.
+func Register(scheme string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[scheme] = open
+}
+
+// Resolve returns the backend that loc is on.
+func Resolve(loc string) (FS, error) {
+	if prefix, _ := split(loc); prefix != "" {
+		u, err := url.Parse(prefix)
+		if err != nil {
+			return nil, err
+		}
+		openersMu.Lock()
+		open := openers[u.Scheme]
+		openersMu.Unlock()
+		if open == nil {
+			return nil, fmt.Errorf("unsupported location: %s", loc)
+		}
+		return open(u)
+	}
+	// The archive file itself is on the local disk.
+	if _, inner, ok := archive.Split(loc); ok && inner != "" {
+		return archives, nil
+	}
+	return Local, nil
+}
+
+// IsLocal reports whether loc is an ordinary path on the local disk.
+func IsLocal(loc string) bool {
+	if Scheme(loc) != "" {
+		return false
+	}
+	fsys, err := Resolve(loc)
+	return err == nil && fsys == Local
+}
+
+// Same reports whether a and b are on the same backend, so that one can be
+// renamed to the other.
+func Same(a, b string) bool {
+	fa, err := Resolve(a)
+	if err != nil {
+		return false
+	}
+	fb, err := Resolve(b)
+	return err == nil && fa == fb
+}
+
+func ReadDir(dir string) ([]fs.FileInfo, error) {
+	fsys, err := Resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.ReadDir(dir)
+}
+
+func Stat(name string) (fs.FileInfo, error) {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Stat(name)
+}
+
+func Lstat(name string) (fs.FileInfo, error) {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Lstat(name)
+}
+
+func Readlink(name string) (string, error) {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	return fsys.Readlink(name)
+}
+
+func Open(name string) (io.ReadCloser, error) {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(name)
+}
+
+func Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Create(name, perm)
+}
+
+func Mkdir(name string, perm fs.FileMode) error {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	return fsys.Mkdir(name, perm)
+}
+
+func Remove(name string) error {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	return fsys.Remove(name)
+}
+
+// MkdirAll makes dir along with any missing parents.
+func MkdirAll(dir string, perm fs.FileMode) error {
+	fsys, err := Resolve(dir)
+	if err != nil {
+		return err
+	}
+	if fsys == Local {
+		return Local.mkdirAll(dir, perm)
+	}
+	if info, err := fsys.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if parent := Dir(dir); parent != dir {
+		if err := MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	if err := fsys.Mkdir(dir, perm); err != nil {
+		// Someone else may have made it in the meantime.
+		if info, serr := fsys.Stat(dir); serr == nil && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func Symlink(target, name string) error {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	return fsys.Symlink(target, name)
+}
+
+func Chmod(name string, mode fs.FileMode) error {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	return fsys.Chmod(name, mode)
+}
+
+func Chtimes(name string, atime, mtime time.Time) error {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	return fsys.Chtimes(name, atime, mtime)
+}
+
+// Rename moves oldname to newname, which must be on the same backend.
+func Rename(oldname, newname string) error {
+	fsys, err := Resolve(oldname)
+	if err != nil {
+		return err
+	}
+	if !Same(oldname, newname) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: errors.New("not on the same filesystem")}
+	}
+	return fsys.Rename(oldname, newname)
+}
+
+// RemoveAll removes name and everything below it. A name that does not
+// exist is not an error.
+func RemoveAll(name string) error {
+	fsys, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	if fsys == Local {
+		return Local.removeAll(name)
+	}
+	return removeAll(fsys, name)
+}
+
+func removeAll(fsys FS, name string) error {
+	info, err := fsys.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := removeAll(fsys, Join(name, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return fsys.Remove(name)
+}
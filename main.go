@@ -5,17 +5,23 @@ import (
 	"os"
 
 	"cfiler/internal/app"
+	"cfiler/internal/sftpfs"
+	"cfiler/internal/vfs"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	vfs.Register("sftp", sftpfs.Open)
+
 	p := tea.NewProgram(
 		app.New(),
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
-	if _, err := p.Run(); err != nil {
+	_, err := p.Run()
+	sftpfs.CloseAll()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}